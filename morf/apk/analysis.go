@@ -54,7 +54,8 @@ func StartCliExtraction(apkPath string, db *gorm.DB, is_db_req bool) {
 		fileName = apkPath
 	}
 
-	scanner_data := StartSecScan(utils.GetInputDir() + fileName)
+	patterns := LoadPatternSet()
+	scanner_data := ScoreSecrets(VerifySecrets(markSuppressedSecrets(packageModel.PackageName, StartSecScan(utils.GetInputDir()+fileName, patterns))))
 	secret_data, secret_error := json.Marshal(scanner_data)
 
 	if secret_error != nil {
@@ -62,6 +63,10 @@ func StartCliExtraction(apkPath string, db *gorm.DB, is_db_req bool) {
 	}
 
 	secret := utils.CreateSecretModel(fileName, packageModel, metadata, scanner_data, secret_data)
+	secret.PatternSetVersion = patterns.Version
+	secret.Firebase = StartFirebaseAnalysis(utils.GetResDir())
+	secret.Endpoints = ExtractEndpoints(utils.GetSourceDir(), utils.GetResDir())
+	secret.Deeplinks = BuildDeeplinkCatalog(secret.PackageDataModel.PackageName, secret.Activities)
 
 	if is_db_req {
 		database.InsertSecrets(secret, db)
@@ -81,6 +86,35 @@ func StartCliExtraction(apkPath string, db *gorm.DB, is_db_req bool) {
 	utils.CreateReport(fs, secret, json_data, secret_data, fileName)
 }
 
+// StartCliSecretsScan reports the secrets in the dex files of an APK without
+// decompiling it or collecting metadata. The partial result is not stored in
+// the database so later full scans of the APK are not skipped as duplicates.
+func StartCliSecretsScan(apkPath string) {
+	fs := utils.GetAppFS()
+	fileName := filepath.Base(apkPath)
+
+	packageModel := ExtractPackageData(apkPath)
+	patterns := LoadPatternSet()
+	scanner_data := ScoreSecrets(VerifySecrets(markSuppressedSecrets(packageModel.PackageName, StartSecretsOnlyScan(apkPath, patterns))))
+	secret_data, secret_error := json.Marshal(scanner_data)
+	if secret_error != nil {
+		log.Error(secret_error)
+	}
+
+	secret := utils.CreateSecretModel(fileName, packageModel, models.MetaDataModel{}, scanner_data, secret_data)
+	secret.PatternSetVersion = patterns.Version
+	json_data, json_error := json.MarshalIndent(secret, "", " ")
+	if json_error != nil {
+		log.Error(json_error)
+	}
+
+	if !utils.CheckBackUpDirExists(fs) {
+		utils.CreateBackUpDir(fs)
+	}
+
+	utils.CreateReport(fs, secret, json_data, secret_data, fileName)
+}
+
 func StartJiraProcess(jiramodel models.JiraModel, db *gorm.DB, c *gin.Context) {
 	apk_path := utils.DownloadFileUsingSlack(jiramodel, c)
 	if apk_path == "" {
@@ -96,13 +130,15 @@ func StartJiraProcess(jiramodel models.JiraModel, db *gorm.DB, c *gin.Context) {
 		if apk_data != nil {
 			log.Error(apk_data)
 		}
+		secrets.SecretModel = ScoreSecrets(markSuppressedSecrets(secrets.PackageDataModel.PackageName, secrets.SecretModel))
 		utils.CookJiraComment(jiramodel, secrets, c)
 		return
 	}
 
 	packageModel := ExtractPackageData(apk_path)
 	metadata := StartMetaDataCollection(apk_path)
-	scanner_data := StartSecScan(utils.GetInputDir() + apk_path)
+	patterns := LoadPatternSet()
+	scanner_data := ScoreSecrets(VerifySecrets(markSuppressedSecrets(packageModel.PackageName, StartSecScan(utils.GetInputDir()+apk_path, patterns))))
 	secret_data, secret_error := json.Marshal(scanner_data)
 
 	if secret_error != nil {
@@ -110,12 +146,36 @@ func StartJiraProcess(jiramodel models.JiraModel, db *gorm.DB, c *gin.Context) {
 	}
 
 	secret := utils.CreateSecretModel(apk_path, packageModel, metadata, scanner_data, secret_data)
+	secret.PatternSetVersion = patterns.Version
+	secret.Firebase = StartFirebaseAnalysis(utils.GetResDir())
+	secret.Endpoints = ExtractEndpoints(utils.GetSourceDir(), utils.GetResDir())
+	secret.Deeplinks = BuildDeeplinkCatalog(secret.PackageDataModel.PackageName, secret.Activities)
 	database.InsertSecrets(secret, db)
 
 	// Comment the data to JIRA ticket
 	utils.CookJiraComment(jiramodel, secret, c)
 }
 
+// markSuppressedSecrets flags the findings covered by the baseline file or by
+// suppressions stored in the database for the package
+func markSuppressedSecrets(packageName string, findings []models.SecretModel) []models.SecretModel {
+	suppressions := database.GetSuppressions(packageName)
+
+	baseline, err := utils.LoadBaseline(utils.GetAppFS(), utils.GetBaselinePath())
+	if err != nil {
+		log.Warn(err)
+	}
+	suppressions = append(suppressions, baseline.Suppressions...)
+
+	if len(suppressions) == 0 {
+		return findings
+	}
+
+	marked := utils.ApplySuppressions(findings, packageName, suppressions, time.Now())
+	log.Infof("%d of %d findings suppressed", len(marked)-len(utils.UnsuppressedSecrets(marked)), len(marked))
+	return marked
+}
+
 // Helper function to create metadata response
 func createMetadataResponse(secret models.Secrets) gin.H {
 	return gin.H{
@@ -156,6 +216,7 @@ func createAPIResponse(secret models.Secrets, scannerData []models.SecretModel)
 			"minSdk":             secret.Metadata.AndroidManifest.UsesMinSdkVersion,
 			"targetSdk":          secret.Metadata.AndroidManifest.UsesTargetSdkVersion,
 			"permissions":        secret.Metadata.AndroidManifest.UsesPermissions,
+			"application":        secret.Metadata.AndroidManifest.Application,
 			"activities":         metadataResponse["activities"],
 			"services":           metadataResponse["services"],
 			"contentProviders":   metadataResponse["contentProviders"],
@@ -166,6 +227,10 @@ func createAPIResponse(secret models.Secrets, scannerData []models.SecretModel)
 			"resourceData":       createResourceDataResponse(secret.Metadata.ResourceData),
 			"secretCount":        len(scannerData),
 			"secrets":            scannerData,
+			"firebase":           secret.Firebase,
+			"endpoints":          secret.Endpoints,
+			"deeplinks":          secret.Deeplinks,
+			"patternSetVersion":  secret.PatternSetVersion,
 			"createdAt":          time.Now().Format(time.RFC3339),
 		},
 	}
@@ -177,7 +242,8 @@ func createAPIResponse(secret models.Secrets, scannerData []models.SecretModel)
 func processAPKData(apkPath string) (models.Secrets, []models.SecretModel, []byte, error) {
 	packageModel := ExtractPackageData(apkPath)
 	metadata := StartMetaDataCollection(apkPath)
-	scannerData := StartSecScan(utils.GetInputDir() + apkPath)
+	patterns := LoadPatternSet()
+	scannerData := ScoreSecrets(VerifySecrets(markSuppressedSecrets(packageModel.PackageName, StartSecScan(utils.GetInputDir()+apkPath, patterns))))
 
 	secretData, secretError := json.Marshal(scannerData)
 	if secretError != nil {
@@ -186,21 +252,37 @@ func processAPKData(apkPath string) (models.Secrets, []models.SecretModel, []byt
 	}
 
 	secret := utils.CreateSecretModel(apkPath, packageModel, metadata, scannerData, secretData)
+	secret.PatternSetVersion = patterns.Version
+	secret.Firebase = StartFirebaseAnalysis(utils.GetResDir())
+	secret.Endpoints = ExtractEndpoints(utils.GetSourceDir(), utils.GetResDir())
+	secret.Deeplinks = BuildDeeplinkCatalog(secret.PackageDataModel.PackageName, secret.Activities)
 	return secret, scannerData, secretData, nil
 }
 
 // handleExistingAPK handles the case when an APK is already in the database
 func handleExistingAPK(jsonData string, isSlack bool, slackData models.SlackData, c *gin.Context) gin.H {
-	if isSlack {
-		utils.RespondSecretsToSlack(slackData, c, jsonData)
-		return nil
-	}
-
 	existingSecret, err := response.ParseExistingSecret(jsonData)
 	if err != nil {
 		return response.CreateErrorResponse("Error parsing existing data")
 	}
 
+	// Suppressions may have changed since the APK was scanned
+	existingSecret.SecretModel = ScoreSecrets(markSuppressedSecrets(existingSecret.PackageDataModel.PackageName, existingSecret.SecretModel))
+	if len(existingSecret.Deeplinks) == 0 {
+		// Scans stored before the catalog existed still have their components
+		existingSecret.Deeplinks = BuildDeeplinkCatalog(existingSecret.PackageDataModel.PackageName, existingSecret.Activities)
+	}
+
+	if isSlack {
+		if data, err := json.Marshal(existingSecret); err == nil {
+			jsonData = string(data)
+		} else {
+			log.Error("Error marshaling existing data:", err)
+		}
+		utils.RespondSecretsToSlack(slackData, c, jsonData)
+		return nil
+	}
+
 	// Create response handlers
 	apiHandler := response.NewAPIResponseHandler(existingSecret, existingSecret.SecretModel)
 	metadataHandler := response.NewMetadataHandler(existingSecret.Metadata)
@@ -273,7 +355,11 @@ func StartExtractProcess(apkPath string, db *gorm.DB, c *gin.Context, isSlack bo
 
 	// Return response
 	if isSlack {
-		utils.RespondSecretsToSlack(slackData, c, string(secretData))
+		slackPayload, err := json.Marshal(secret)
+		if err != nil {
+			log.Error("Error marshaling scan data:", err)
+		}
+		utils.RespondSecretsToSlack(slackData, c, string(slackPayload))
 		return nil
 	}
 
@@ -0,0 +1,363 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"fmt"
+	"io"
+	"morf/models"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	vip "github.com/spf13/viper"
+	_ "modernc.org/sqlite"
+)
+
+// Directories of an APK whose files may embed archives, databases or dex files
+var embeddedFileDirs = []string{"assets", "res/raw"}
+
+// Magic numbers of the embedded formats that are opened
+var (
+	zipMagic    = []byte("PK\x03\x04")
+	gzipMagic   = []byte{0x1f, 0x8b}
+	sqliteMagic = []byte("SQLite format 3\x00")
+	dexMagic    = []byte("dex\n")
+)
+
+// maxSQLiteRows caps the rows dumped from each table of an embedded database
+const maxSQLiteRows = 10000
+
+// ArchiveLimits bounds how far embedded files are unpacked
+type ArchiveLimits struct {
+	MaxDepth     int
+	MaxFileSize  int64
+	MaxTotalSize int64
+}
+
+// DefaultArchiveLimits returns the built-in unpacking limits
+func DefaultArchiveLimits() ArchiveLimits {
+	return ArchiveLimits{
+		MaxDepth:     3,
+		MaxFileSize:  32 << 20,
+		MaxTotalSize: 256 << 20,
+	}
+}
+
+// LoadArchiveLimits applies any archives.* settings from viper on top of the defaults
+func LoadArchiveLimits() ArchiveLimits {
+	limits := DefaultArchiveLimits()
+	if vip.IsSet("archives.max_depth") {
+		limits.MaxDepth = vip.GetInt("archives.max_depth")
+	}
+	if vip.IsSet("archives.max_file_size") {
+		limits.MaxFileSize = vip.GetInt64("archives.max_file_size")
+	}
+	if vip.IsSet("archives.max_total_size") {
+		limits.MaxTotalSize = vip.GetInt64("archives.max_total_size")
+	}
+	return limits
+}
+
+// isEmbeddedFile reports whether a path relative to the APK root is under one
+// of the directories that may embed files
+func isEmbeddedFile(relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
+	for _, dir := range embeddedFileDirs {
+		if strings.HasPrefix(relPath, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// embeddedScanner unpacks embedded files and everything nested in them.
+// budget is the number of bytes that may still be extracted, shared by every
+// file of one scan.
+type embeddedScanner struct {
+	matcher *SecretMatcher
+	limits  ArchiveLimits
+	budget  int64
+}
+
+// ScanEmbeddedFiles opens the zip, jar, apk, gzip, SQLite and dex files under
+// assets/ and res/raw/ and scans their contents. Nested files are reported
+// with virtual paths such as assets/data.zip!/config.json. Plain text files
+// at the top level are left to ScanDir.
+func (m *SecretMatcher) ScanEmbeddedFiles(root string, limits ArchiveLimits) []models.SecretModel {
+	scanner := m.newEmbeddedScanner(limits)
+	var findings []models.SecretModel
+	for _, dir := range embeddedFileDirs {
+		walkFiles(filepath.Join(root, dir), func(path string) {
+			relPath, err := filepath.Rel(root, path)
+			if err != nil {
+				return
+			}
+
+			file, err := os.Open(path)
+			if err != nil {
+				log.Debugf("Unable to open %s: %v", path, err)
+				return
+			}
+			defer file.Close()
+
+			findings = append(findings, scanner.scanFile(path, filepath.ToSlash(relPath), file, false)...)
+		})
+	}
+
+	sortFindings(findings)
+	return findings
+}
+
+// newEmbeddedScanner returns a scanner for the embedded files of one scan,
+// all of which draw on the same MaxTotalSize budget
+func (m *SecretMatcher) newEmbeddedScanner(limits ArchiveLimits) *embeddedScanner {
+	return &embeddedScanner{matcher: m, limits: limits, budget: limits.MaxTotalSize}
+}
+
+// scanFile unpacks a single file from the APK and scans it. Text content at
+// the top level is only scanned when scanText is set.
+func (s *embeddedScanner) scanFile(path string, relPath string, reader io.Reader, scanText bool) []models.SecretModel {
+	data, err := s.read(reader)
+	if err != nil {
+		log.Warnf("Skipping %s: %v", path, err)
+		return nil
+	}
+	return s.scan(path, relPath, data, 0, scanText)
+}
+
+// read reads an embedded file within the per-file limit and the remaining budget
+func (s *embeddedScanner) read(reader io.Reader) ([]byte, error) {
+	limit := min(s.limits.MaxFileSize, s.budget)
+	data, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("over the extraction limit of %d bytes", limit)
+	}
+	s.budget -= int64(len(data))
+	return data, nil
+}
+
+// scan dispatches on the content type of a file found at the given depth
+func (s *embeddedScanner) scan(path string, relPath string, data []byte, depth int, scanText bool) []models.SecretModel {
+	container := bytes.HasPrefix(data, zipMagic) || bytes.HasPrefix(data, gzipMagic)
+	if container && depth >= s.limits.MaxDepth {
+		log.Warnf("Not unpacking %s: nested deeper than %d levels", path, s.limits.MaxDepth)
+		return nil
+	}
+
+	switch {
+	case bytes.HasPrefix(data, zipMagic):
+		return s.scanZip(path, relPath, data, depth)
+	case bytes.HasPrefix(data, gzipMagic):
+		return s.scanGzip(path, relPath, data, depth)
+	case bytes.HasPrefix(data, sqliteMagic):
+		return s.scanSQLite(path, relPath, data)
+	case bytes.HasPrefix(data, dexMagic):
+		strs, err := ParseDex(data)
+		if err != nil {
+			log.Debugf("Unable to parse %s: %v", path, err)
+			return nil
+		}
+		return s.matcher.ScanDexStrings(path, strs)
+	case scanText || depth > 0:
+		return s.matcher.scanContent(path, relPath, data)
+	}
+	return nil
+}
+
+// scanZip scans every entry of a zip, jar or apk
+func (s *embeddedScanner) scanZip(archivePath string, relPath string, data []byte, depth int) []models.SecretModel {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		log.Debugf("Unable to open %s: %v", archivePath, err)
+		return nil
+	}
+
+	var findings []models.SecretModel
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		if entry.UncompressedSize64 > uint64(s.limits.MaxFileSize) {
+			log.Warnf("Skipping %s!/%s: %d bytes is over the extraction limit", archivePath, entry.Name, entry.UncompressedSize64)
+			continue
+		}
+
+		content, err := entry.Open()
+		if err != nil {
+			log.Debugf("Unable to open %s!/%s: %v", archivePath, entry.Name, err)
+			continue
+		}
+		entryData, err := s.read(content)
+		content.Close()
+		if err != nil {
+			log.Warnf("Skipping %s!/%s: %v", archivePath, entry.Name, err)
+			continue
+		}
+
+		findings = append(findings, s.scan(archivePath+"!/"+entry.Name, relPath+"!/"+entry.Name, entryData, depth+1, true)...)
+	}
+	return findings
+}
+
+// scanGzip decompresses a gzip stream, naming its content after the original
+// file name in the header or else the compressed file without .gz
+func (s *embeddedScanner) scanGzip(archivePath string, relPath string, data []byte, depth int) []models.SecretModel {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		log.Debugf("Unable to open %s: %v", archivePath, err)
+		return nil
+	}
+	defer reader.Close()
+
+	content, err := s.read(reader)
+	if err != nil {
+		log.Warnf("Skipping %s: %v", archivePath, err)
+		return nil
+	}
+
+	name := path.Base(reader.Name)
+	if reader.Name == "" {
+		name = strings.TrimSuffix(path.Base(filepath.ToSlash(archivePath)), ".gz")
+	}
+	return s.scan(archivePath+"!/"+name, relPath+"!/"+name, content, depth+1, true)
+}
+
+// scanSQLite dumps the text and blob columns of every table in an embedded
+// database. Findings are reported against the table, with the row number as
+// their line number.
+func (s *embeddedScanner) scanSQLite(dbPath string, relPath string, data []byte) []models.SecretModel {
+	tmp, err := os.CreateTemp("", "morf-*.db")
+	if err != nil {
+		log.Errorf("Unable to create a temporary database: %v", err)
+		return nil
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	tmp.Close()
+	if err != nil {
+		log.Errorf("Unable to write a temporary database: %v", err)
+		return nil
+	}
+
+	db, err := sql.Open("sqlite", "file:"+tmp.Name()+"?mode=ro&immutable=1")
+	if err != nil {
+		log.Warnf("Unable to open %s: %v", dbPath, err)
+		return nil
+	}
+	defer db.Close()
+
+	tables, err := sqliteTables(db)
+	if err != nil {
+		log.Warnf("Unable to list the tables of %s: %v", dbPath, err)
+		return nil
+	}
+
+	var findings []models.SecretModel
+	for _, table := range tables {
+		listing, rowOfLine, err := dumpSQLiteTable(db, table)
+		if err != nil {
+			log.Warnf("Unable to read table %s of %s: %v", table, dbPath, err)
+			continue
+		}
+
+		for _, finding := range s.matcher.scanContent(dbPath+"!/"+table, relPath+"!/"+table, listing) {
+			finding.LineNo = rowOfLine[finding.LineNo-1]
+			finding.EndLineNo = finding.LineNo
+			finding.ColumnNo, finding.EndColumnNo = 0, 0
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+// sqliteTables lists the user tables of a database
+func sqliteTables(db *sql.DB) ([]string, error) {
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+// dumpSQLiteTable renders each text value of a table as column="value" on a
+// line of its own and returns the 1-based row number of every line
+func dumpSQLiteTable(db *sql.DB, table string) ([]byte, []int, error) {
+	query := fmt.Sprintf(`SELECT * FROM "%s" LIMIT %d`, strings.ReplaceAll(table, `"`, `""`), maxSQLiteRows)
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var listing bytes.Buffer
+	var rowOfLine []int
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for row := 1; rows.Next(); row++ {
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, nil, err
+		}
+
+		for i, value := range values {
+			var text string
+			switch v := value.(type) {
+			case string:
+				text = v
+			case []byte:
+				if bytes.IndexByte(v, 0) >= 0 {
+					continue
+				}
+				text = string(v)
+			default:
+				continue
+			}
+			listing.WriteString(columns[i] + "=" + strconv.Quote(text) + "\n")
+			rowOfLine = append(rowOfLine, row)
+		}
+	}
+	return listing.Bytes(), rowOfLine, rows.Err()
+}
@@ -0,0 +1,233 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// resourcesEntry is the name of the compiled resource table inside an APK
+const resourcesEntry = "resources.arsc"
+
+// maxResourcesSize caps the size of a resource table read from an APK
+const maxResourcesSize = 64 << 20
+
+// Chunk types of a resource table
+const (
+	arscChunkTable   = 0x0002
+	arscChunkPackage = 0x0200
+	arscChunkType    = 0x0201
+)
+
+// Flags of type chunks and their entries
+const (
+	arscTypeSparse   = 0x01
+	arscTypeOffset16 = 0x02
+	arscEntryComplex = 0x0001
+	arscEntryCompact = 0x0008
+)
+
+// maxReferenceDepth bounds how many references are followed to a value
+const maxReferenceDepth = 8
+
+// resourceValue is a simple value of the resource table
+type resourceValue struct {
+	Type          uint8
+	Data          uint32
+	DefaultConfig bool
+}
+
+// resourceTable holds the simple values of a resources.arsc, preferring the
+// value of the default configuration when a resource has several
+type resourceTable struct {
+	strings   []string
+	typeNames map[uint32][]string
+	values    map[uint32]resourceValue
+}
+
+// parseResourceTable decodes the packages, types and simple entries of a
+// resources.arsc. Complex entries such as styles and plurals are skipped.
+func parseResourceTable(data []byte) (*resourceTable, error) {
+	r := &axmlReader{data: data}
+	if r.u16(0) != arscChunkTable {
+		return nil, fmt.Errorf("not a resource table")
+	}
+
+	size := int(r.u32(4))
+	if size > len(data) || r.u16(2) > size {
+		return nil, fmt.Errorf("resource table is truncated")
+	}
+
+	table := &resourceTable{typeNames: make(map[uint32][]string), values: make(map[uint32]resourceValue)}
+	r.chunks(r.u16(2), size, func(offset int, chunkType int, size int) {
+		switch chunkType {
+		case axmlChunkStringPool:
+			if table.strings == nil {
+				table.strings = r.stringPool(offset)
+			}
+		case arscChunkPackage:
+			r.resourcePackage(offset, size, table)
+		}
+	})
+	if r.err != nil {
+		return nil, r.err
+	}
+	return table, nil
+}
+
+// chunks calls visit for each chunk between start and end
+func (r *axmlReader) chunks(start int, end int, visit func(offset int, chunkType int, size int)) {
+	for offset := start; offset+8 <= end && r.err == nil; {
+		chunkType, size := r.u16(offset), int(r.u32(offset+4))
+		if size < 8 || offset+size > end {
+			r.err = fmt.Errorf("resource chunk at %#x has invalid size %d", offset, size)
+			return
+		}
+		visit(offset, chunkType, size)
+		offset += size
+	}
+}
+
+// resourcePackage reads the type names and entries of a package chunk
+func (r *axmlReader) resourcePackage(offset int, size int, table *resourceTable) {
+	id := r.u32(offset + 8)
+	// The header holds the ID, a 128 character name and the offset of the type name pool
+	typeNames := r.stringPool(offset + int(r.u32(offset+268)))
+	table.typeNames[id] = typeNames
+
+	r.chunks(offset+r.u16(offset+2), offset+size, func(chunkOffset int, chunkType int, chunkSize int) {
+		if chunkType == arscChunkType {
+			r.resourceType(chunkOffset, id, table)
+		}
+	})
+}
+
+// resourceType reads the simple entries of a type chunk
+func (r *axmlReader) resourceType(offset int, packageID uint32, table *resourceTable) {
+	typeID, flags := uint32(r.u8(offset+8)), r.u8(offset+9)
+	entryCount := int(r.u32(offset + 12))
+	entriesStart := offset + int(r.u32(offset+16))
+	indexStart := offset + r.u16(offset+2)
+	if entryCount > len(r.data)/2 {
+		r.err = fmt.Errorf("resource type at %#x has too many entries", offset)
+		return
+	}
+
+	// The default configuration is all zero after its size
+	config := offset + 20
+	configSize := int(r.u32(config))
+	defaultConfig := r.check(config, configSize)
+	for i := 4; defaultConfig && i < configSize; i++ {
+		defaultConfig = r.data[config+i] == 0
+	}
+
+	for i := 0; i < entryCount && r.err == nil; i++ {
+		index, entryOffset := i, 0
+		switch {
+		case flags&arscTypeSparse != 0:
+			index, entryOffset = r.u16(indexStart+4*i), 4*r.u16(indexStart+4*i+2)
+		case flags&arscTypeOffset16 != 0:
+			if entryOffset = r.u16(indexStart + 2*i); entryOffset == 0xffff {
+				continue
+			}
+			entryOffset *= 4
+		default:
+			value := r.u32(indexStart + 4*i)
+			if value == axmlNoEntry {
+				continue
+			}
+			entryOffset = int(value)
+		}
+
+		entry := entriesStart + entryOffset
+		entryFlags := r.u16(entry + 2)
+		var value resourceValue
+		switch {
+		case entryFlags&arscEntryCompact != 0:
+			value = resourceValue{Type: uint8(entryFlags >> 8), Data: r.u32(entry + 4)}
+		case entryFlags&arscEntryComplex != 0:
+			continue
+		default:
+			valueOffset := entry + r.u16(entry)
+			value = resourceValue{Type: uint8(r.u8(valueOffset + 3)), Data: r.u32(valueOffset + 4)}
+		}
+		value.DefaultConfig = defaultConfig
+
+		id := packageID<<24 | typeID<<16 | uint32(index)
+		if existing, ok := table.values[id]; !ok || (defaultConfig && !existing.DefaultConfig) {
+			table.values[id] = value
+		}
+	}
+}
+
+// typeName returns the type of a resource ID, such as string or xml
+func (t *resourceTable) typeName(id uint32) string {
+	names := t.typeNames[id>>24]
+	if index := int(id>>16&0xff) - 1; index >= 0 && index < len(names) {
+		return names[index]
+	}
+	return ""
+}
+
+// resolveString returns the value of a string resource, following
+// references to other resources
+func (t *resourceTable) resolveString(id uint32) (string, bool) {
+	if t.typeName(id) != "string" {
+		return "", false
+	}
+	for depth := 0; depth < maxReferenceDepth; depth++ {
+		value, ok := t.values[id]
+		if !ok {
+			return "", false
+		}
+		switch value.Type {
+		case AttrTypeString:
+			if int(value.Data) < len(t.strings) {
+				return t.strings[value.Data], true
+			}
+			return "", false
+		case AttrTypeReference:
+			id = value.Data
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// resolveStringReferences replaces references to string resources in the
+// attributes of an element tree with the strings, turning them into string
+// attributes. Other references, such as @xml/ files, are left as they are.
+func resolveStringReferences(element *XMLElement, table *resourceTable) {
+	for i := range element.Attributes {
+		attr := &element.Attributes[i]
+		if attr.Type != AttrTypeReference {
+			continue
+		}
+		if value, ok := table.resolveString(attr.Data); ok {
+			attr.Type = AttrTypeString
+			attr.Value = value
+		} else if table.typeName(attr.Data) == "string" {
+			log.Debugf("Unresolved string resource %s in %s of <%s>", attr.Value, attr.Name, element.Name)
+		}
+	}
+	for _, child := range element.Children {
+		resolveStringReferences(child, table)
+	}
+}
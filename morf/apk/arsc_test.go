@@ -0,0 +1,181 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// testResource is an entry of a test resource table. Strings go to the
+// global string pool; other types keep Data as it is.
+type testResource struct {
+	ID       uint32
+	Type     uint8
+	Data     uint32
+	Value    string
+	Language string
+}
+
+// buildTestARSC encodes a resource table with one package whose type chunks
+// hold the resources, one chunk per type and configuration in the given order
+func buildTestARSC(typeNames []string, resources []testResource) []byte {
+	var strs []string
+	type chunkKey struct {
+		typeID   uint32
+		language string
+	}
+	var order []chunkKey
+	entries := make(map[chunkKey][]testResource)
+	for _, resource := range resources {
+		if resource.Type == AttrTypeString {
+			resource.Data = uint32(len(strs))
+			strs = append(strs, resource.Value)
+		}
+		key := chunkKey{resource.ID >> 16 & 0xff, resource.Language}
+		if _, ok := entries[key]; !ok {
+			order = append(order, key)
+		}
+		entries[key] = append(entries[key], resource)
+	}
+
+	var types []byte
+	for _, key := range order {
+		entryCount := 0
+		for _, resource := range entries[key] {
+			entryCount = max(entryCount, int(resource.ID&0xffff)+1)
+		}
+		offsets := make([]uint32, entryCount)
+		for i := range offsets {
+			offsets[i] = axmlNoEntry
+		}
+		var body []byte
+		for _, resource := range entries[key] {
+			offsets[resource.ID&0xffff] = uint32(len(body))
+			// Entry: size, flags, key; then the value: size, zero, type, data
+			body = binary.LittleEndian.AppendUint16(body, 8)
+			body = binary.LittleEndian.AppendUint16(body, 0)
+			body = binary.LittleEndian.AppendUint32(body, 0)
+			body = binary.LittleEndian.AppendUint16(body, 8)
+			body = append(body, 0, resource.Type)
+			body = binary.LittleEndian.AppendUint32(body, resource.Data)
+		}
+
+		const headerSize = 20 + 64
+		chunk := binary.LittleEndian.AppendUint16(nil, arscChunkType)
+		chunk = binary.LittleEndian.AppendUint16(chunk, headerSize)
+		chunk = binary.LittleEndian.AppendUint32(chunk, uint32(headerSize+4*entryCount+len(body)))
+		chunk = append(chunk, byte(key.typeID), 0, 0, 0)
+		chunk = binary.LittleEndian.AppendUint32(chunk, uint32(entryCount))
+		chunk = binary.LittleEndian.AppendUint32(chunk, uint32(headerSize+4*entryCount))
+		config := make([]byte, 64)
+		binary.LittleEndian.PutUint32(config, 64)
+		copy(config[8:10], key.language)
+		chunk = append(chunk, config...)
+		for _, offset := range offsets {
+			chunk = binary.LittleEndian.AppendUint32(chunk, offset)
+		}
+		types = append(types, append(chunk, body...)...)
+	}
+
+	// Package header: ID, name, the offsets of the type and key name pools
+	// and the last public type and key
+	typePool := buildTestStringPool(typeNames, false)
+	keyPool := buildTestStringPool(nil, false)
+	pkg := binary.LittleEndian.AppendUint16(nil, arscChunkPackage)
+	pkg = binary.LittleEndian.AppendUint16(pkg, 288)
+	pkg = binary.LittleEndian.AppendUint32(pkg, uint32(288+len(typePool)+len(keyPool)+len(types)))
+	pkg = binary.LittleEndian.AppendUint32(pkg, 0x7f)
+	pkg = append(pkg, make([]byte, 256)...)
+	for _, field := range []uint32{288, uint32(len(typeNames)), uint32(288 + len(typePool)), 0, 0} {
+		pkg = binary.LittleEndian.AppendUint32(pkg, field)
+	}
+	pkg = append(pkg, typePool...)
+	pkg = append(pkg, keyPool...)
+	pkg = append(pkg, types...)
+
+	table := testChunk(arscChunkTable, 12, 1)
+	table = append(table, buildTestStringPool(strs, true)...)
+	table = append(table, pkg...)
+	binary.LittleEndian.PutUint32(table[4:], uint32(len(table)))
+	return table
+}
+
+// testResources has a localized version name that precedes the default one,
+// a string referencing it and an xml file reference
+func testResources() []byte {
+	return buildTestARSC([]string{"attr", "string", "xml"}, []testResource{
+		{ID: 0x7f020000, Type: AttrTypeString, Value: "2.4.1-fr", Language: "fr"},
+		{ID: 0x7f020000, Type: AttrTypeString, Value: "2.4.1"},
+		{ID: 0x7f020001, Type: AttrTypeReference, Data: 0x7f020000},
+		{ID: 0x7f030000, Type: AttrTypeString, Value: "res/xml/network_security_config.xml"},
+	})
+}
+
+func TestParseResourceTable(t *testing.T) {
+	table, err := parseResourceTable(testResources())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id    uint32
+		want  string
+		found bool
+	}{
+		{0x7f020000, "2.4.1", true}, // the default configuration wins
+		{0x7f020001, "2.4.1", true}, // references are followed
+		{0x7f030000, "", false},     // not a string resource
+		{0x7f020009, "", false},     // missing
+	}
+	for _, tt := range tests {
+		if got, found := table.resolveString(tt.id); got != tt.want || found != tt.found {
+			t.Errorf("resolveString(%#x) = %q, %v, want %q, %v", tt.id, got, found, tt.want, tt.found)
+		}
+	}
+
+	if _, err := parseResourceTable([]byte{2, 0, 12, 0, 0xff, 0xff, 0, 0}); err == nil {
+		t.Error("expected an error for a truncated table")
+	}
+}
+
+func TestExtractPackageDataResolvesReferences(t *testing.T) {
+	manifest := testManifest()
+	manifest.Attributes[1] = testAttr("versionName", 0x0101021c, AttrTypeReference, 0x7f020000, "@0x7f020000")
+	application := manifest.Child("application")
+	application.Attributes = append(application.Attributes,
+		testAttr("networkSecurityConfig", 0x01010527, AttrTypeReference, 0x7f030000, "@0x7f030000"))
+	axml := buildTestAXML(manifest, false)
+
+	apkPath := writeTestAPK(t, map[string][]byte{manifestEntry: axml, resourcesEntry: testResources()})
+	if pkg := ExtractPackageData(apkPath); pkg.VersionName != "2.4.1" {
+		t.Errorf("version name = %q, want 2.4.1", pkg.VersionName)
+	}
+	parsed, err := ReadManifest(apkPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parsed.Child("application").AndroidString("networkSecurityConfig"); got != "@0x7f030000" {
+		t.Errorf("networkSecurityConfig = %q, want the xml resource ID", got)
+	}
+
+	// Without resources.arsc the reference cannot be resolved
+	apkPath = writeTestAPK(t, map[string][]byte{manifestEntry: axml})
+	if pkg := ExtractPackageData(apkPath); pkg.VersionName != "" {
+		t.Errorf("version name = %q, want it empty when unresolved", pkg.VersionName)
+	}
+}
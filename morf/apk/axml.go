@@ -0,0 +1,527 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf16"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// AndroidNamespace is the namespace URI of the android: attributes
+const AndroidNamespace = "http://schemas.android.com/apk/res/android"
+
+// manifestEntry is the name of the binary manifest inside an APK
+const manifestEntry = "AndroidManifest.xml"
+
+// maxManifestSize caps the size of a binary manifest read from an APK
+const maxManifestSize = 16 << 20
+
+// Chunk types of a binary XML file
+const (
+	axmlChunkStringPool   = 0x0001
+	axmlChunkXML          = 0x0003
+	axmlChunkStartElement = 0x0102
+	axmlChunkEndElement   = 0x0103
+	axmlChunkResourceMap  = 0x0180
+)
+
+// axmlUTF8Flag marks a string pool holding UTF-8 instead of UTF-16 strings
+const axmlUTF8Flag = 1 << 8
+
+// axmlNoEntry is the string index of an absent namespace or raw value
+const axmlNoEntry = 0xffffffff
+
+// Res_value data types of attribute values
+const (
+	AttrTypeNull       = 0x00
+	AttrTypeReference  = 0x01
+	AttrTypeAttribute  = 0x02
+	AttrTypeString     = 0x03
+	AttrTypeFloat      = 0x04
+	AttrTypeIntDec     = 0x10
+	AttrTypeIntHex     = 0x11
+	AttrTypeIntBoolean = 0x12
+)
+
+// androidAttributeNames maps the framework resource IDs of the attributes
+// read from manifests to their names. Android resolves attributes by ID, so
+// the ID wins over a renamed or stripped name in the string pool.
+var androidAttributeNames = map[uint32]string{
+	0x01010000: "theme",
+	0x01010001: "label",
+	0x01010002: "icon",
+	0x01010003: "name",
+	0x01010006: "permission",
+	0x01010007: "readPermission",
+	0x01010008: "writePermission",
+	0x01010009: "protectionLevel",
+	0x0101000b: "sharedUserId",
+	0x0101000e: "enabled",
+	0x0101000f: "debuggable",
+	0x01010010: "exported",
+	0x01010011: "process",
+	0x01010012: "taskAffinity",
+	0x01010018: "authorities",
+	0x0101001b: "grantUriPermissions",
+	0x0101001c: "priority",
+	0x0101001d: "launchMode",
+	0x01010024: "value",
+	0x01010025: "resource",
+	0x01010026: "mimeType",
+	0x01010027: "scheme",
+	0x01010028: "host",
+	0x01010029: "port",
+	0x0101002a: "path",
+	0x0101002b: "pathPrefix",
+	0x0101002c: "pathPattern",
+	0x01010202: "targetActivity",
+	0x0101020c: "minSdkVersion",
+	0x0101021b: "versionCode",
+	0x0101021c: "versionName",
+	0x0101026c: "anyDensity",
+	0x01010270: "targetSdkVersion",
+	0x01010271: "maxSdkVersion",
+	0x01010272: "testOnly",
+	0x01010280: "allowBackup",
+	0x01010284: "smallScreens",
+	0x01010285: "normalScreens",
+	0x01010286: "largeScreens",
+	0x0101028d: "resizeable",
+	0x0101028e: "required",
+	0x010102bf: "xlargeScreens",
+	0x010104ea: "extractNativeLibs",
+	0x010104eb: "fullBackupContent",
+	0x010104ec: "usesCleartextTraffic",
+	0x010104ee: "autoVerify",
+	0x01010527: "networkSecurityConfig",
+	0x01010572: "compileSdkVersion",
+	0x01010573: "compileSdkVersionCodename",
+	0x01010603: "requestLegacyExternalStorage",
+	0x0101063e: "dataExtractionRules",
+}
+
+// XMLAttribute is an attribute of a binary XML element. Value holds the
+// attribute formatted as a string: booleans as true or false, decimal
+// integers in decimal and references as @0x7f010001. References to string
+// resources resolved through resources.arsc become string attributes whose
+// Data is still the resource ID.
+type XMLAttribute struct {
+	Namespace  string
+	Name       string
+	ResourceID uint32
+	Type       uint8
+	Data       uint32
+	Value      string
+}
+
+// Bool reports the attribute as a boolean, accepting typed booleans and the
+// strings true and false
+func (a *XMLAttribute) Bool() (bool, bool) {
+	if a == nil {
+		return false, false
+	}
+	switch a.Type {
+	case AttrTypeIntBoolean, AttrTypeIntDec, AttrTypeIntHex:
+		return a.Data != 0, true
+	case AttrTypeString:
+		value, err := strconv.ParseBool(a.Value)
+		return value, err == nil
+	}
+	return false, false
+}
+
+// Int reports the attribute as an integer, accepting typed integers and numeric strings
+func (a *XMLAttribute) Int() (int, bool) {
+	if a == nil {
+		return 0, false
+	}
+	switch a.Type {
+	case AttrTypeIntDec, AttrTypeIntHex:
+		return int(int32(a.Data)), true
+	case AttrTypeString:
+		value, err := strconv.Atoi(a.Value)
+		return value, err == nil
+	}
+	return 0, false
+}
+
+// XMLElement is an element of a binary XML document
+type XMLElement struct {
+	Namespace  string
+	Name       string
+	Line       int
+	Attributes []XMLAttribute
+	Children   []*XMLElement
+}
+
+// Attr returns the attribute with the given namespace URI and name, or nil
+func (e *XMLElement) Attr(namespace string, name string) *XMLAttribute {
+	if e == nil {
+		return nil
+	}
+	for i := range e.Attributes {
+		if e.Attributes[i].Namespace == namespace && e.Attributes[i].Name == name {
+			return &e.Attributes[i]
+		}
+	}
+	return nil
+}
+
+// AndroidAttr returns the android: attribute with the given name, or nil
+func (e *XMLElement) AndroidAttr(name string) *XMLAttribute {
+	return e.Attr(AndroidNamespace, name)
+}
+
+// AndroidString returns the value of an android: attribute, or "" when it is absent
+func (e *XMLElement) AndroidString(name string) string {
+	if attr := e.AndroidAttr(name); attr != nil {
+		return attr.Value
+	}
+	return ""
+}
+
+// Child returns the first child element with the given name, or nil
+func (e *XMLElement) Child(name string) *XMLElement {
+	if e == nil {
+		return nil
+	}
+	for _, child := range e.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// ChildrenNamed returns the child elements with any of the given names, in document order
+func (e *XMLElement) ChildrenNamed(names ...string) []*XMLElement {
+	if e == nil {
+		return nil
+	}
+	var children []*XMLElement
+	for _, child := range e.Children {
+		for _, name := range names {
+			if child.Name == name {
+				children = append(children, child)
+				break
+			}
+		}
+	}
+	return children
+}
+
+// ReadManifest parses the binary AndroidManifest.xml of an APK
+func ReadManifest(apkPath string) (*XMLElement, error) {
+	reader, err := zip.OpenReader(apkPath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return readZippedManifest(&reader.Reader)
+}
+
+// readZippedManifest parses the binary manifest of an opened APK, with
+// references to string resources resolved through resources.arsc
+func readZippedManifest(archive *zip.Reader) (*XMLElement, error) {
+	var manifestFile, resourcesFile *zip.File
+	for _, file := range archive.File {
+		switch file.Name {
+		case manifestEntry:
+			manifestFile = file
+		case resourcesEntry:
+			resourcesFile = file
+		}
+	}
+	if manifestFile == nil {
+		return nil, fmt.Errorf("%s not found in the APK", manifestEntry)
+	}
+
+	data, err := readZipEntry(manifestFile, maxManifestSize)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := ParseAXML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if resourcesFile == nil {
+		log.Debugf("%s not found in the APK, string references stay unresolved", resourcesEntry)
+		return manifest, nil
+	}
+	resources, err := readZipEntry(resourcesFile, maxResourcesSize)
+	if err == nil {
+		var table *resourceTable
+		if table, err = parseResourceTable(resources); err == nil {
+			resolveStringReferences(manifest, table)
+		}
+	}
+	if err != nil {
+		log.Warnf("Error reading %s, string references stay unresolved: %v", resourcesEntry, err)
+	}
+	return manifest, nil
+}
+
+// readZipEntry reads an entry of an APK, refusing entries over limit bytes
+func readZipEntry(file *zip.File, limit uint64) ([]byte, error) {
+	if file.UncompressedSize64 > limit {
+		return nil, fmt.Errorf("%s is %d bytes, over the %d byte limit", file.Name, file.UncompressedSize64, limit)
+	}
+
+	content, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	return io.ReadAll(io.LimitReader(content, int64(limit)))
+}
+
+// axmlReader reads little-endian values from a binary XML file. The first out
+// of bounds read is remembered in err and every later read returns zero.
+type axmlReader struct {
+	data []byte
+	err  error
+}
+
+func (r *axmlReader) check(offset int, size int) bool {
+	if r.err != nil {
+		return false
+	}
+	if offset < 0 || size < 0 || offset > len(r.data)-size {
+		r.err = fmt.Errorf("binary XML offset %#x out of bounds", offset)
+		return false
+	}
+	return true
+}
+
+func (r *axmlReader) u8(offset int) int {
+	if !r.check(offset, 1) {
+		return 0
+	}
+	return int(r.data[offset])
+}
+
+func (r *axmlReader) u16(offset int) int {
+	if !r.check(offset, 2) {
+		return 0
+	}
+	return int(binary.LittleEndian.Uint16(r.data[offset:]))
+}
+
+func (r *axmlReader) u32(offset int) uint32 {
+	if !r.check(offset, 4) {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(r.data[offset:])
+}
+
+// ParseAXML decodes a binary XML document, as found in AndroidManifest.xml,
+// into its root element. Attribute names are resolved from their resource
+// IDs where the ID is a known framework attribute.
+func ParseAXML(data []byte) (*XMLElement, error) {
+	r := &axmlReader{data: data}
+	if r.u16(0) != axmlChunkXML {
+		return nil, fmt.Errorf("not a binary XML file")
+	}
+	headerSize, size := r.u16(2), int(r.u32(4))
+	if r.err != nil {
+		return nil, r.err
+	}
+	// Some packers pad or truncate the declared size; the data is what counts
+	end := min(size, len(data))
+
+	var (
+		strs      []string
+		resources []uint32
+		root      *XMLElement
+		stack     []*XMLElement
+	)
+	str := func(index uint32) string {
+		if index == axmlNoEntry || int(index) >= len(strs) {
+			return ""
+		}
+		return strs[index]
+	}
+
+	for offset := headerSize; offset+8 <= end; {
+		chunkType, chunkHeader, chunkSize := r.u16(offset), r.u16(offset+2), int(r.u32(offset+4))
+		if r.err != nil {
+			return nil, r.err
+		}
+		if chunkSize < 8 || chunkHeader > chunkSize || offset+chunkSize > len(data) {
+			return nil, fmt.Errorf("binary XML chunk %#x at %#x has an invalid size", chunkType, offset)
+		}
+
+		switch chunkType {
+		case axmlChunkStringPool:
+			strs = r.stringPool(offset)
+		case axmlChunkResourceMap:
+			for id := offset + chunkHeader; id+4 <= offset+chunkSize; id += 4 {
+				resources = append(resources, r.u32(id))
+			}
+		case axmlChunkStartElement:
+			element := r.element(offset, chunkHeader, str, resources)
+			if parent := len(stack); parent > 0 {
+				stack[parent-1].Children = append(stack[parent-1].Children, element)
+			} else if root == nil {
+				root = element
+			}
+			stack = append(stack, element)
+		case axmlChunkEndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		offset += chunkSize
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("binary XML has no elements")
+	}
+	return root, nil
+}
+
+// element decodes a start element chunk
+func (r *axmlReader) element(offset int, headerSize int, str func(uint32) string, resources []uint32) *XMLElement {
+	ext := offset + headerSize
+	element := &XMLElement{
+		Line:      int(r.u32(offset + 8)),
+		Namespace: str(r.u32(ext)),
+		Name:      str(r.u32(ext + 4)),
+	}
+
+	attributeStart, attributeSize, attributeCount := r.u16(ext+8), r.u16(ext+10), r.u16(ext+12)
+	if attributeSize < 20 {
+		attributeSize = 20
+	}
+	for i := 0; i < attributeCount && r.err == nil; i++ {
+		item := ext + attributeStart + i*attributeSize
+		nameIndex := r.u32(item + 4)
+		attr := XMLAttribute{
+			Namespace: str(r.u32(item)),
+			Name:      str(nameIndex),
+			Type:      uint8(r.u8(item + 15)),
+			Data:      r.u32(item + 16),
+		}
+		if int(nameIndex) < len(resources) {
+			attr.ResourceID = resources[nameIndex]
+			if name, known := androidAttributeNames[attr.ResourceID]; known {
+				attr.Namespace, attr.Name = AndroidNamespace, name
+			}
+		}
+		attr.Value = formatAttributeValue(attr, str(r.u32(item+8)), str)
+		element.Attributes = append(element.Attributes, attr)
+	}
+	return element
+}
+
+// formatAttributeValue renders a typed attribute value the way aapt prints it
+func formatAttributeValue(attr XMLAttribute, raw string, str func(uint32) string) string {
+	switch attr.Type {
+	case AttrTypeString:
+		return str(attr.Data)
+	case AttrTypeIntBoolean:
+		return strconv.FormatBool(attr.Data != 0)
+	case AttrTypeIntDec:
+		return strconv.Itoa(int(int32(attr.Data)))
+	case AttrTypeIntHex:
+		return fmt.Sprintf("0x%x", attr.Data)
+	case AttrTypeReference:
+		return fmt.Sprintf("@0x%08x", attr.Data)
+	case AttrTypeAttribute:
+		return fmt.Sprintf("?0x%08x", attr.Data)
+	case AttrTypeFloat:
+		return strconv.FormatFloat(float64(math.Float32frombits(attr.Data)), 'g', -1, 32)
+	case AttrTypeNull:
+		return raw
+	}
+	if raw != "" {
+		return raw
+	}
+	return fmt.Sprintf("(type 0x%02x)0x%x", attr.Type, attr.Data)
+}
+
+// stringPool decodes the strings of a string pool chunk
+func (r *axmlReader) stringPool(offset int) []string {
+	count, flags := int(r.u32(offset+8)), r.u32(offset+16)
+	stringsStart := offset + int(r.u32(offset+20))
+	if count > len(r.data)/4 {
+		r.err = fmt.Errorf("binary XML string pool at %#x is larger than the file", offset)
+		return nil
+	}
+	r.check(offset+28, 4*count)
+
+	strs := make([]string, count)
+	for i := 0; i < count && r.err == nil; i++ {
+		start := stringsStart + int(r.u32(offset+28+4*i))
+		if flags&axmlUTF8Flag != 0 {
+			strs[i] = r.utf8String(start)
+		} else {
+			strs[i] = r.utf16String(start)
+		}
+	}
+	return strs
+}
+
+// utf8String decodes a string of a UTF-8 pool: the UTF-16 length, the byte
+// length, each one or two bytes, then the bytes
+func (r *axmlReader) utf8String(offset int) string {
+	_, offset = r.utf8Length(offset)
+	length, offset := r.utf8Length(offset)
+	if !r.check(offset, length) {
+		return ""
+	}
+	return string(r.data[offset : offset+length])
+}
+
+func (r *axmlReader) utf8Length(offset int) (int, int) {
+	length := r.u8(offset)
+	if length&0x80 != 0 {
+		return (length&0x7f)<<8 | r.u8(offset+1), offset + 2
+	}
+	return length, offset + 1
+}
+
+// utf16String decodes a string of a UTF-16 pool: the length in code units,
+// one or two of them, then the code units
+func (r *axmlReader) utf16String(offset int) string {
+	length := r.u16(offset)
+	offset += 2
+	if length&0x8000 != 0 {
+		length = (length&0x7fff)<<16 | r.u16(offset)
+		offset += 2
+	}
+	if !r.check(offset, 2*length) {
+		return ""
+	}
+
+	units := make([]uint16, length)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(r.data[offset+2*i:])
+	}
+	return string(utf16.Decode(units))
+}
@@ -0,0 +1,287 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+)
+
+// buildTestAXML encodes an element tree as binary XML the way aapt2 lays it
+// out: the attribute names with resource IDs first in the string pool, a
+// resource map, the android namespace around the root element
+func buildTestAXML(root *XMLElement, utf8Pool bool) []byte {
+	var strs []string
+	var ids []uint32
+	index := make(map[string]uint32)
+	ref := func(key string, value string) uint32 {
+		if value == "" && key == value {
+			return axmlNoEntry
+		}
+		if i, ok := index[key]; ok {
+			return i
+		}
+		index[key] = uint32(len(strs))
+		strs = append(strs, value)
+		return index[key]
+	}
+	str := func(value string) uint32 { return ref(value, value) }
+	attrName := func(attr XMLAttribute) uint32 {
+		if attr.ResourceID == 0 {
+			return str(attr.Name)
+		}
+		return ref(fmt.Sprintf("%s@%#x", attr.Name, attr.ResourceID), attr.Name)
+	}
+
+	var walk func(element *XMLElement, visit func(*XMLElement))
+	walk = func(element *XMLElement, visit func(*XMLElement)) {
+		visit(element)
+		for _, child := range element.Children {
+			walk(child, visit)
+		}
+	}
+	walk(root, func(element *XMLElement) {
+		for _, attr := range element.Attributes {
+			if count := len(strs); attr.ResourceID != 0 && attrName(attr) == uint32(count) {
+				ids = append(ids, attr.ResourceID)
+			}
+		}
+	})
+
+	var body []byte
+	chunk := testChunk
+
+	prefix, uri := str("android"), str(AndroidNamespace)
+	body = append(body, chunk(0x0100, 16, 1, axmlNoEntry, prefix, uri)...)
+	var encode func(element *XMLElement)
+	encode = func(element *XMLElement) {
+		fields := []uint32{uint32(element.Line), axmlNoEntry, str(element.Namespace), str(element.Name),
+			20 | 20<<16, uint32(len(element.Attributes)), 0}
+		for _, attr := range element.Attributes {
+			raw := uint32(axmlNoEntry)
+			data := attr.Data
+			if attr.Type == AttrTypeString {
+				raw = str(attr.Value)
+				data = raw
+			}
+			fields = append(fields, str(attr.Namespace), attrName(attr), raw, 8|uint32(attr.Type)<<24, data)
+		}
+		body = append(body, chunk(axmlChunkStartElement, 16, fields...)...)
+		for _, child := range element.Children {
+			encode(child)
+		}
+		body = append(body, chunk(axmlChunkEndElement, 16, uint32(element.Line), axmlNoEntry, str(element.Namespace), str(element.Name))...)
+	}
+	encode(root)
+	body = append(body, chunk(0x0101, 16, 1, axmlNoEntry, prefix, uri)...)
+
+	pool := buildTestStringPool(strs, utf8Pool)
+	resourceMap := chunk(axmlChunkResourceMap, 8, ids...)
+
+	file := chunk(axmlChunkXML, 8)
+	file = append(file, pool...)
+	file = append(file, resourceMap...)
+	file = append(file, body...)
+	binary.LittleEndian.PutUint32(file[4:], uint32(len(file)))
+	return file
+}
+
+// testChunk encodes a chunk header followed by 32 bit fields
+func testChunk(chunkType int, headerSize int, fields ...uint32) []byte {
+	out := binary.LittleEndian.AppendUint16(nil, uint16(chunkType))
+	out = binary.LittleEndian.AppendUint16(out, uint16(headerSize))
+	out = binary.LittleEndian.AppendUint32(out, uint32(8+4*len(fields)))
+	for _, field := range fields {
+		out = binary.LittleEndian.AppendUint32(out, field)
+	}
+	return out
+}
+
+// buildTestStringPool encodes a string pool chunk in UTF-8 or UTF-16
+func buildTestStringPool(strs []string, utf8Pool bool) []byte {
+	var data []byte
+	var offsets []uint32
+	for _, s := range strs {
+		offsets = append(offsets, uint32(len(data)))
+		if utf8Pool {
+			units := utf16.Encode([]rune(s))
+			data = append(data, byte(len(units)), byte(len(s)))
+			data = append(data, s...)
+			data = append(data, 0)
+		} else {
+			units := utf16.Encode([]rune(s))
+			data = binary.LittleEndian.AppendUint16(data, uint16(len(units)))
+			for _, unit := range units {
+				data = binary.LittleEndian.AppendUint16(data, unit)
+			}
+			data = append(data, 0, 0)
+		}
+	}
+	for len(data)%4 != 0 {
+		data = append(data, 0)
+	}
+	flags := uint32(0)
+	if utf8Pool {
+		flags = axmlUTF8Flag
+	}
+	pool := testChunk(axmlChunkStringPool, 28, append([]uint32{uint32(len(strs)), 0, flags, uint32(28 + 4*len(strs)), 0}, offsets...)...)
+	pool = append(pool, data...)
+	binary.LittleEndian.PutUint32(pool[4:], uint32(len(pool)))
+	return pool
+}
+
+// writeTestAPK writes an APK with the given entries to a temporary directory
+func writeTestAPK(t *testing.T, files map[string][]byte) string {
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	for name, content := range files {
+		entry, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry.Write(content)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	apkPath := filepath.Join(t.TempDir(), "app.apk")
+	if err := os.WriteFile(apkPath, archive.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return apkPath
+}
+
+func testAttr(name string, id uint32, attrType uint8, data uint32, value string) XMLAttribute {
+	namespace := AndroidNamespace
+	if id == 0 {
+		namespace = ""
+	}
+	return XMLAttribute{Namespace: namespace, Name: name, ResourceID: id, Type: attrType, Data: data, Value: value}
+}
+
+func testManifest() *XMLElement {
+	return &XMLElement{Name: "manifest", Line: 1,
+		Attributes: []XMLAttribute{
+			testAttr("versionCode", 0x0101021b, AttrTypeIntDec, 42, "42"),
+			testAttr("versionName", 0x0101021c, AttrTypeString, 0, "1.2.3"),
+			testAttr("package", 0, AttrTypeString, 0, "com.example.app"),
+			testAttr("platformBuildVersionCode", 0, AttrTypeIntDec, 34, "34"),
+		},
+		Children: []*XMLElement{
+			{Name: "uses-sdk", Line: 2, Attributes: []XMLAttribute{
+				testAttr("minSdkVersion", 0x0101020c, AttrTypeIntDec, 24, "24"),
+			}},
+			{Name: "supports-screens", Line: 3, Attributes: []XMLAttribute{
+				testAttr("smallScreens", 0x01010284, AttrTypeIntBoolean, 0, "false"),
+			}},
+			{Name: "application", Line: 4, Children: []*XMLElement{
+				{Name: "activity", Line: 5, Attributes: []XMLAttribute{
+					// An obfuscated attribute name, resolved through its resource ID
+					testAttr("a1", 0x01010003, AttrTypeString, 0, "com.example.app.Main"),
+					testAttr("exported", 0x01010010, AttrTypeIntBoolean, 0xffffffff, "true"),
+				}, Children: []*XMLElement{
+					{Name: "intent-filter", Line: 6, Attributes: []XMLAttribute{
+						testAttr("autoVerify", 0x010104ee, AttrTypeIntBoolean, 0xffffffff, "true"),
+					}, Children: []*XMLElement{
+						{Name: "action", Line: 7, Attributes: []XMLAttribute{testAttr("name", 0x01010003, AttrTypeString, 0, "android.intent.action.VIEW")}},
+						{Name: "data", Line: 8, Attributes: []XMLAttribute{
+							testAttr("scheme", 0x01010027, AttrTypeString, 0, "https"),
+							testAttr("host", 0x01010028, AttrTypeString, 0, "example.com"),
+						}},
+					}},
+				}},
+			}},
+		},
+	}
+}
+
+func TestParseAXML(t *testing.T) {
+	for _, utf8Pool := range []bool{false, true} {
+		manifest, err := ParseAXML(buildTestAXML(testManifest(), utf8Pool))
+		if err != nil {
+			t.Fatalf("utf8=%v: %v", utf8Pool, err)
+		}
+
+		if got := manifest.Attr("", "package"); got == nil || got.Value != "com.example.app" {
+			t.Errorf("utf8=%v: package = %+v", utf8Pool, got)
+		}
+		if code, ok := manifest.AndroidAttr("versionCode").Int(); !ok || code != 42 {
+			t.Errorf("utf8=%v: versionCode = %d, %v", utf8Pool, code, ok)
+		}
+
+		activity := manifest.Child("application").Child("activity")
+		if name := activity.AndroidString("name"); name != "com.example.app.Main" {
+			t.Errorf("utf8=%v: obfuscated name attribute resolved to %q", utf8Pool, name)
+		}
+		if exported, ok := activity.AndroidAttr("exported").Bool(); !ok || !exported {
+			t.Errorf("utf8=%v: exported = %v, %v", utf8Pool, exported, ok)
+		}
+		if data := activity.Child("intent-filter").Child("data"); data.AndroidString("host") != "example.com" || data.Line != 8 {
+			t.Errorf("utf8=%v: data element = %+v", utf8Pool, data)
+		}
+	}
+}
+
+func TestParseAXMLRejectsMalformed(t *testing.T) {
+	data := buildTestAXML(testManifest(), false)
+	for name, input := range map[string][]byte{
+		"empty":     nil,
+		"text":      []byte("<manifest/>"),
+		"truncated": data[:len(data)/2],
+	} {
+		if _, err := ParseAXML(input); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestExtractPackageData(t *testing.T) {
+	apkPath := writeTestAPK(t, map[string][]byte{
+		manifestEntry:                       buildTestAXML(testManifest(), false),
+		"res/mipmap-xxhdpi-v4/ic.png":       nil,
+		"res/drawable-hdpi/ic.png":          nil,
+		"lib/arm64-v8a/libnative.so":        nil,
+		"lib/armeabi-v7a/libnative.so":      nil,
+		"lib/arm64-v8a/libother.so":         nil,
+		"res/layout/main.xml":               nil,
+		"assets/lib/arm64-v8a/not-a-lib.so": nil,
+	})
+
+	pkg := ExtractPackageData(apkPath)
+	if pkg.PackageName != "com.example.app" || pkg.VersionCode != "42" || pkg.VersionName != "1.2.3" {
+		t.Errorf("unexpected package details: %+v", pkg)
+	}
+	// No targetSdkVersion or compileSdkVersion: aapt falls back to minSdkVersion and platformBuildVersionCode
+	if pkg.SdkVersion != "24" || pkg.TargetSdk != "24" || pkg.CompileSdkVersion != "34" {
+		t.Errorf("unexpected SDK versions: min %s, target %s, compile %s", pkg.SdkVersion, pkg.TargetSdk, pkg.CompileSdkVersion)
+	}
+	if got := []string(pkg.SupportScreens); len(got) != 3 || got[0] != "normal" {
+		t.Errorf("supported screens = %v", got)
+	}
+	if got := []string(pkg.Densities); len(got) != 2 || got[0] != "240" || got[1] != "480" {
+		t.Errorf("densities = %v", got)
+	}
+	if got := []string(pkg.NativeCode); len(got) != 2 || got[0] != "arm64-v8a" || got[1] != "armeabi-v7a" {
+		t.Errorf("native code = %v", got)
+	}
+}
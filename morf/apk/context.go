@@ -0,0 +1,122 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"morf/models"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// maxContextLines caps the configured number of lines around a finding
+	maxContextLines = 20
+	// maxContextLineLength is the longest context line kept, so minified files stay readable
+	maxContextLineLength = 240
+)
+
+// AttachContext stores the source around each finding, the given number of lines
+// before and after its span; a negative number disables it.
+// Every file is read once, however many findings it has.
+func AttachContext(findings []models.SecretModel, lines int) {
+	if lines < 0 {
+		return
+	}
+	if lines > maxContextLines {
+		lines = maxContextLines
+	}
+
+	byFile := make(map[string][]int)
+	var files []string
+	for i, finding := range findings {
+		if finding.FileLocation == "" || finding.LineNo == 0 {
+			continue
+		}
+		if _, exists := byFile[finding.FileLocation]; !exists {
+			files = append(files, finding.FileLocation)
+		}
+		byFile[finding.FileLocation] = append(byFile[finding.FileLocation], i)
+	}
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			log.Debugf("Unable to read %s for context: %v", file, err)
+			continue
+		}
+
+		index := newLineIndex(content)
+		for _, i := range byFile[file] {
+			findings[i].Context = sourceContext(index, findings[i], lines)
+		}
+	}
+}
+
+// sourceContext returns the lines of a finding's span plus lines on either side
+func sourceContext(index lineIndex, finding models.SecretModel, lines int) *models.SecretContext {
+	lineCount := len(index.starts)
+	if finding.LineNo > lineCount {
+		return nil
+	}
+
+	endLine := finding.EndLineNo
+	if endLine < finding.LineNo {
+		endLine = finding.LineNo
+	}
+	first := max(1, finding.LineNo-lines)
+	last := min(lineCount, endLine+lines)
+
+	context := &models.SecretContext{StartLine: first}
+	for lineNo := first; lineNo <= last; lineNo++ {
+		line := string(index.line(lineNo))
+		switch {
+		case lineNo == finding.LineNo && lineNo == endLine:
+			line = clipContextLine(line, finding.ColumnNo-1, finding.EndColumnNo-1)
+		case lineNo == finding.LineNo:
+			line = clipContextLine(line, finding.ColumnNo-1, len(line))
+		default:
+			line = clipContextLine(line, 0, 0)
+		}
+		context.Lines = append(context.Lines, line)
+	}
+	return context
+}
+
+// clipContextLine shortens an overlong line to a window around the byte range
+// [start, end), marking the cut ends with "..."
+func clipContextLine(line string, start int, end int) string {
+	if len(line) <= maxContextLineLength {
+		return line
+	}
+
+	start = max(0, min(start, len(line)))
+	end = max(start, min(end, len(line)))
+	padding := max(0, (maxContextLineLength-(end-start))/2)
+	from := max(0, start-padding)
+	to := min(len(line), max(end+padding, from+maxContextLineLength))
+
+	clipped := strings.ToValidUTF8(line[from:to], "")
+	if from > 0 {
+		clipped = "..." + clipped
+	}
+	if to < len(line) {
+		clipped += "..."
+	}
+	return clipped
+}
@@ -0,0 +1,238 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"morf/models"
+	"net/url"
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+
+	vip "github.com/spf13/viper"
+)
+
+// maxXORLength is the longest decoded blob tried against every single-byte XOR key
+const maxXORLength = 256
+
+// encodedLiteralRegex finds runs of characters that may hold an encoded value
+var encodedLiteralRegex = regexp.MustCompile(`[A-Za-z0-9+/_%.~\-]+={0,2}`)
+
+var (
+	base64Alphabet    = regexp.MustCompile(`^[A-Za-z0-9+/]+={0,2}$`)
+	base64URLAlphabet = regexp.MustCompile(`^[A-Za-z0-9_\-]*[_\-][A-Za-z0-9_\-]*={0,2}$`)
+	hexAlphabet       = regexp.MustCompile(`^(?:[0-9a-fA-F]{2})+$`)
+	urlEscape         = regexp.MustCompile(`%[0-9a-fA-F]{2}`)
+
+	wholeEncodedLiteral = regexp.MustCompile(`^` + encodedLiteralRegex.String() + `$`)
+)
+
+// DecodeConfig bounds the decode-and-rescan pass
+type DecodeConfig struct {
+	Enabled   bool
+	MinLength int
+	MaxLength int
+	MaxDepth  int
+	XOR       bool
+}
+
+// DefaultDecodeConfig returns the built-in decoding limits
+func DefaultDecodeConfig() DecodeConfig {
+	return DecodeConfig{
+		Enabled:   true,
+		MinLength: 16,
+		MaxLength: 4096,
+		MaxDepth:  2,
+		XOR:       true,
+	}
+}
+
+// LoadDecodeConfig applies any decode.* settings from viper on top of the defaults
+func LoadDecodeConfig() DecodeConfig {
+	config := DefaultDecodeConfig()
+	if vip.IsSet("decode.enabled") {
+		config.Enabled = vip.GetBool("decode.enabled")
+	}
+	if vip.IsSet("decode.min_length") {
+		config.MinLength = vip.GetInt("decode.min_length")
+	}
+	if vip.IsSet("decode.max_length") {
+		config.MaxLength = vip.GetInt("decode.max_length")
+	}
+	if vip.IsSet("decode.max_depth") {
+		config.MaxDepth = vip.GetInt("decode.max_depth")
+	}
+	if vip.IsSet("decode.xor") {
+		config.XOR = vip.GetBool("decode.xor")
+	}
+	return config
+}
+
+// EnableDecoding makes the matcher decode encoded literals and match the
+// patterns against the decoded text as well
+func (m *SecretMatcher) EnableDecoding(config DecodeConfig) *SecretMatcher {
+	m.decoding = nil
+	if config.Enabled && config.MaxDepth > 0 {
+		m.decoding = &config
+	}
+	return m
+}
+
+// decodedBlob is printable text revealed by a chain of decoders
+type decodedBlob struct {
+	chain []string
+	data  []byte
+}
+
+// literalDecoder turns an encoded value into bytes
+type literalDecoder struct {
+	name   string
+	decode func(data []byte) ([]byte, bool)
+}
+
+var literalDecoders = []literalDecoder{
+	{"base64", decodeBase64},
+	{"base64url", decodeBase64URL},
+	{"hex", decodeHex},
+	{"url", decodeURL},
+}
+
+// scanDecoded decodes every candidate literal in content and matches the
+// patterns against the result. Findings keep the position of the encoded
+// literal and record the decoders that revealed them.
+func (m *SecretMatcher) scanDecoded(path string, relPath string, content []byte, lines lineIndex) []models.SecretModel {
+	var findings []models.SecretModel
+	for _, loc := range encodedLiteralRegex.FindAllIndex(content, -1) {
+		if length := loc[1] - loc[0]; length < m.decoding.MinLength || length > m.decoding.MaxLength {
+			continue
+		}
+
+		lineNo, column := lines.position(loc[0])
+		endLineNo, endColumn := lines.position(loc[1])
+		for _, blob := range m.decoding.decode(content[loc[0]:loc[1]]) {
+			for _, finding := range m.matchPatterns(path, relPath, blob.data, newLineIndex(blob.data)) {
+				finding.LineNo, finding.ColumnNo = lineNo, column
+				finding.EndLineNo, finding.EndColumnNo = endLineNo, endColumn
+				finding.DecodingChain = blob.chain
+				findings = append(findings, finding)
+			}
+		}
+	}
+	return findings
+}
+
+// decode returns the printable results of every decoder chain, up to MaxDepth long
+func (c *DecodeConfig) decode(literal []byte) []decodedBlob {
+	var blobs []decodedBlob
+	c.expand(literal, nil, &blobs)
+	return blobs
+}
+
+func (c *DecodeConfig) expand(data []byte, chain []string, blobs *[]decodedBlob) {
+	if len(chain) >= c.MaxDepth {
+		return
+	}
+
+	for _, decoder := range literalDecoders {
+		decoded, ok := decoder.decode(data)
+		if !ok {
+			continue
+		}
+		next := append(chain[:len(chain):len(chain)], decoder.name)
+
+		if isPrintableText(decoded) {
+			*blobs = append(*blobs, decodedBlob{chain: next, data: decoded})
+			if len(decoded) >= c.MinLength && wholeEncodedLiteral.Match(decoded) {
+				c.expand(decoded, next, blobs)
+			}
+			continue
+		}
+
+		if c.XOR && len(next) < c.MaxDepth && len(decoded) <= maxXORLength {
+			for key := 1; key < 256; key++ {
+				if plain, ok := xorPrintable(decoded, byte(key)); ok {
+					*blobs = append(*blobs, decodedBlob{chain: append(next[:len(next):len(next)], fmt.Sprintf("xor:0x%02x", key)), data: plain})
+				}
+			}
+		}
+	}
+}
+
+func decodeBase64(data []byte) ([]byte, bool) {
+	if !base64Alphabet.Match(data) {
+		return nil, false
+	}
+	decoded, err := base64.RawStdEncoding.DecodeString(string(bytes.TrimRight(data, "=")))
+	return decoded, err == nil && len(decoded) > 0
+}
+
+func decodeBase64URL(data []byte) ([]byte, bool) {
+	if !base64URLAlphabet.Match(data) {
+		return nil, false
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(string(bytes.TrimRight(data, "=")))
+	return decoded, err == nil && len(decoded) > 0
+}
+
+func decodeHex(data []byte) ([]byte, bool) {
+	if !hexAlphabet.Match(data) {
+		return nil, false
+	}
+	decoded, err := hex.DecodeString(string(data))
+	return decoded, err == nil
+}
+
+func decodeURL(data []byte) ([]byte, bool) {
+	if !urlEscape.Match(data) {
+		return nil, false
+	}
+	decoded, err := url.PathUnescape(string(data))
+	return []byte(decoded), err == nil
+}
+
+// xorPrintable XORs data with key and returns the result when it is printable
+// ASCII, bailing out at the first byte that is not
+func xorPrintable(data []byte, key byte) ([]byte, bool) {
+	for _, b := range data {
+		if b^key < 0x20 || b^key > 0x7e {
+			return nil, false
+		}
+	}
+
+	plain := make([]byte, len(data))
+	for i, b := range data {
+		plain[i] = b ^ key
+	}
+	return plain, true
+}
+
+// isPrintableText reports whether decoded bytes are text worth matching against
+func isPrintableText(data []byte) bool {
+	if len(data) == 0 || !utf8.Valid(data) {
+		return false
+	}
+	for _, r := range string(data) {
+		if !unicode.IsPrint(r) && r != '\t' && r != '\n' && r != '\r' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,233 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"morf/models"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const categoryBrowsable = "android.intent.category.BROWSABLE"
+
+// filterAuthority is a host and port declared together on a <data> element
+type filterAuthority struct {
+	host string
+	port string
+}
+
+// filterPath is a path, path prefix or path pattern of an intent filter
+type filterPath struct {
+	value string
+	match string
+}
+
+// BuildDeeplinkCatalog lists the URIs the intent filters of the activities
+// accept. Like Android's IntentFilter, the <data> elements of a filter are
+// merged: every scheme is combined with every host and port, and every
+// authority with every path. Paths only apply when there is an authority,
+// and filters without a scheme accept no deeplinks.
+func BuildDeeplinkCatalog(packageName string, activities []models.ManifestActivityInfo) models.DeeplinkArray {
+	catalog := models.DeeplinkArray{}
+	seen := make(map[models.Deeplink]bool)
+	appLinks := 0
+
+	for _, activity := range activities {
+		for _, filter := range activity.IntentFilters {
+			for _, deeplink := range expandIntentFilter(packageName, activity, filter) {
+				if seen[deeplink] {
+					continue
+				}
+				seen[deeplink] = true
+				catalog = append(catalog, deeplink)
+				if deeplink.AppLink {
+					appLinks++
+				}
+				log.Debugf("Deeplink %s opens %s (exported=%v, appLink=%v)", deeplink.URI, deeplink.Component, deeplink.Exported, deeplink.AppLink)
+			}
+		}
+	}
+
+	log.Infof("Found %d deeplinks, %d of them App Links", len(catalog), appLinks)
+	return catalog
+}
+
+// expandIntentFilter expands the scheme, authority and path combinations of
+// an intent filter into deeplinks
+func expandIntentFilter(packageName string, activity models.ManifestActivityInfo, filter models.ManifestFilter) []models.Deeplink {
+	var schemes, mimeTypes []string
+	var authorities []filterAuthority
+	var paths []filterPath
+	for _, data := range filter.Data {
+		schemes = appendUnique(schemes, data.Scheme)
+		mimeTypes = appendUnique(mimeTypes, data.MimeType)
+		if data.Host != "" {
+			authorities = append(authorities, filterAuthority{data.Host, data.Port})
+		}
+		if data.Path != "" {
+			paths = append(paths, filterPath{data.Path, models.DeeplinkPathLiteral})
+		}
+		for _, prefix := range data.PathPrefix {
+			paths = append(paths, filterPath{prefix, models.DeeplinkPathPrefix})
+		}
+		if data.PathPattern != "" {
+			paths = append(paths, filterPath{data.PathPattern, models.DeeplinkPathPattern})
+		}
+	}
+	if len(schemes) == 0 {
+		return nil
+	}
+
+	// Without an authority the filter matches any URI of its schemes, and
+	// without a path any path of its authorities
+	if len(authorities) == 0 {
+		authorities = []filterAuthority{{}}
+		paths = nil
+	}
+	if len(paths) == 0 {
+		paths = []filterPath{{}}
+	}
+
+	action := ""
+	if len(filter.Actions) > 0 {
+		action = filter.Actions[0]
+	}
+	mimeType := ""
+	if len(mimeTypes) > 0 {
+		mimeType = mimeTypes[0]
+	}
+	browsable := false
+	for _, category := range filter.Categories {
+		browsable = browsable || category == categoryBrowsable
+	}
+
+	var deeplinks []models.Deeplink
+	for _, scheme := range schemes {
+		for _, authority := range authorities {
+			for _, path := range paths {
+				deeplink := models.Deeplink{
+					Component: activity.Name,
+					Exported:  activity.Exported,
+					Scheme:    scheme,
+					Host:      authority.host,
+					Port:      authority.port,
+					Path:      path.value,
+					PathMatch: path.match,
+					Action:    action,
+					MimeType:  mimeType,
+					Browsable: browsable,
+					AppLink:   filter.AutoVerify && scheme == "https" && authority.host != "",
+				}
+				deeplink.URI = exampleDeeplinkURI(deeplink)
+				deeplink.Command = deeplinkCommand(packageName, deeplink)
+				deeplinks = append(deeplinks, deeplink)
+			}
+		}
+	}
+	return deeplinks
+}
+
+// exampleDeeplinkURI builds a concrete URI that the deeplink matches,
+// filling host wildcards and path patterns with sample values
+func exampleDeeplinkURI(deeplink models.Deeplink) string {
+	uri := deeplink.Scheme + "://"
+	if deeplink.Host == "" {
+		return uri
+	}
+
+	host := deeplink.Host
+	if strings.HasPrefix(host, "*") {
+		host = "www" + strings.TrimPrefix(host, "*")
+	}
+	uri += host
+	if deeplink.Port != "" {
+		uri += ":" + deeplink.Port
+	}
+
+	if deeplink.PathMatch == models.DeeplinkPathPattern {
+		return uri + examplePathPattern(deeplink.Path)
+	}
+	return uri + deeplink.Path
+}
+
+// examplePathPattern returns a path matching an android:pathPattern, in
+// which "." is any character, "*" repeats the previous character zero or
+// more times and "\" escapes the next character
+func examplePathPattern(pattern string) string {
+	var path strings.Builder
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch {
+		case runes[i] == '\\' && i+1 < len(runes):
+			i++
+			path.WriteRune(runes[i])
+		case runes[i] == '.' && i+1 < len(runes) && runes[i+1] == '*':
+			i++
+			path.WriteString("test")
+		case runes[i] == '.':
+			path.WriteRune('a')
+		case runes[i] == '*':
+			// The previous character is already written once
+		default:
+			path.WriteRune(runes[i])
+		}
+	}
+	return path.String()
+}
+
+// deeplinkCommand returns the adb command that opens the deeplink in the app.
+// adb shell joins its arguments and the device shell parses them again, so
+// every argument of the remote am command is quoted, and the whole remote
+// command is quoted once more for the local shell.
+func deeplinkCommand(packageName string, deeplink models.Deeplink) string {
+	args := []string{"am", "start", "-W"}
+	if deeplink.Action != "" {
+		args = append(args, "-a", deeplink.Action)
+	}
+	args = append(args, "-d", deeplink.URI)
+	if deeplink.MimeType != "" {
+		args = append(args, "-t", deeplink.MimeType)
+	}
+	if packageName != "" {
+		args = append(args, packageName)
+	}
+
+	remote := make([]string, len(args))
+	for i, arg := range args {
+		remote[i] = shellQuote(arg)
+	}
+	return "adb shell " + shellQuote(strings.Join(remote, " "))
+}
+
+// shellQuote quotes a value as a single POSIX shell word
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// appendUnique appends value when it is not empty and not in values yet
+func appendUnique(values []string, value string) []string {
+	if value == "" {
+		return values
+	}
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
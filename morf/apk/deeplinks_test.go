@@ -0,0 +1,168 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"morf/models"
+	"strings"
+	"testing"
+)
+
+func TestBuildDeeplinkCatalog(t *testing.T) {
+	activities := []models.ManifestActivityInfo{
+		{
+			Name:     "com.example.app.LinkActivity",
+			Exported: true,
+			IntentFilters: []models.ManifestFilter{{
+				Actions:    []string{"android.intent.action.VIEW"},
+				Categories: []string{"android.intent.category.DEFAULT", "android.intent.category.BROWSABLE"},
+				AutoVerify: true,
+				// The <data> elements of a filter are merged
+				Data: []models.ManifestFilterData{
+					{Scheme: "https"},
+					{Scheme: "http"},
+					{Host: "*.example.com"},
+					{Path: "/home"},
+					{PathPrefix: []string{"/items/"}},
+					{PathPattern: "/user/.*/profile"},
+				},
+			}},
+		},
+		{
+			Name: "com.example.app.PrivateActivity",
+			IntentFilters: []models.ManifestFilter{
+				{
+					Actions: []string{"android.intent.action.VIEW"},
+					// Paths without a host are ignored
+					Data: []models.ManifestFilterData{{Scheme: "example", Path: "/ignored"}},
+				},
+				{
+					// No scheme, so no deeplinks
+					Actions: []string{"android.intent.action.SEND"},
+					Data:    []models.ManifestFilterData{{MimeType: "text/plain"}},
+				},
+			},
+		},
+	}
+
+	catalog := BuildDeeplinkCatalog("com.example.app", activities)
+	if len(catalog) != 7 {
+		t.Fatalf("got %d deeplinks, want 7: %+v", len(catalog), catalog)
+	}
+
+	uris := make(map[string]models.Deeplink)
+	for _, deeplink := range catalog {
+		uris[deeplink.URI] = deeplink
+	}
+	for _, uri := range []string{
+		"https://www.example.com/home",
+		"https://www.example.com/items/",
+		"https://www.example.com/user/test/profile",
+		"http://www.example.com/home",
+		"http://www.example.com/items/",
+		"http://www.example.com/user/test/profile",
+		"example://",
+	} {
+		if _, ok := uris[uri]; !ok {
+			t.Errorf("missing deeplink %s", uri)
+		}
+	}
+
+	appLink := uris["https://www.example.com/items/"]
+	if !appLink.AppLink || !appLink.Exported || !appLink.Browsable || appLink.PathMatch != models.DeeplinkPathPrefix || appLink.Host != "*.example.com" {
+		t.Errorf("unexpected App Link: %+v", appLink)
+	}
+	if uris["http://www.example.com/home"].AppLink {
+		t.Error("http deeplinks are not App Links")
+	}
+	if private := uris["example://"]; private.Exported || private.Component != "com.example.app.PrivateActivity" {
+		t.Errorf("unexpected private deeplink: %+v", private)
+	}
+
+	want := `adb shell ''\''am'\'' '\''start'\'' '\''-W'\'' '\''-a'\'' '\''android.intent.action.VIEW'\'' '\''-d'\'' '\''https://www.example.com/home'\'' '\''com.example.app'\'''`
+	if got := uris["https://www.example.com/home"].Command; got != want {
+		t.Errorf("got command %q, want %q", got, want)
+	}
+}
+
+// shellWords splits a command the way a POSIX shell does, for commands that
+// only use single quotes and backslash escapes
+func shellWords(command string) []string {
+	var words []string
+	var word strings.Builder
+	inWord, quoted := false, false
+	for i := 0; i < len(command); i++ {
+		switch c := command[i]; {
+		case quoted && c == '\'':
+			quoted = false
+		case quoted:
+			word.WriteByte(c)
+		case c == '\'':
+			quoted, inWord = true, true
+		case c == '\\' && i+1 < len(command):
+			i++
+			word.WriteByte(command[i])
+			inWord = true
+		case c == ' ':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+func TestDeeplinkCommandQuoting(t *testing.T) {
+	uri := "https://example.com/a?x=1&y=it's;$(reboot) #frag"
+	command := deeplinkCommand("com.example.app", models.Deeplink{Action: "android.intent.action.VIEW", URI: uri})
+
+	// The local shell hands adb the remote command as a single argument
+	local := shellWords(command)
+	if len(local) != 3 || local[0] != "adb" || local[1] != "shell" {
+		t.Fatalf("local shell words %q", local)
+	}
+
+	// The device shell sees the URI as one literal argument
+	remote := shellWords(local[2])
+	want := []string{"am", "start", "-W", "-a", "android.intent.action.VIEW", "-d", uri, "com.example.app"}
+	if strings.Join(remote, "\x00") != strings.Join(want, "\x00") {
+		t.Errorf("device shell words %q, want %q", remote, want)
+	}
+}
+
+func TestExamplePathPattern(t *testing.T) {
+	tests := map[string]string{
+		"/user/.*":     "/user/test",
+		"/a.c":         "/aac",
+		"/go*gle":      "/gogle",
+		`/file\.json`:  "/file.json",
+		"/plain/path/": "/plain/path/",
+	}
+	for pattern, want := range tests {
+		if got := examplePathPattern(pattern); got != want {
+			t.Errorf("examplePathPattern(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+}
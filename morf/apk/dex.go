@@ -0,0 +1,350 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// Offsets of the table sizes and offsets in the dex header
+const (
+	dexHeaderSize     = 0x70
+	dexStringIdsSize  = 0x38
+	dexTypeIdsSize    = 0x40
+	dexMethodIdsSize  = 0x58
+	dexClassDefsSize  = 0x60
+	dexClassDefLength = 32
+)
+
+// Dalvik opcodes that load a string constant and the pseudo-instructions
+// that embed data in the instruction stream
+const (
+	opConstString        = 0x1a
+	opConstStringJumbo   = 0x1b
+	packedSwitchPayload  = 0x0100
+	sparseSwitchPayload  = 0x0200
+	fillArrayDataPayload = 0x0300
+)
+
+// dexInstructionWidths is the size of every Dalvik instruction in 16-bit code units
+var dexInstructionWidths = func() [256]int {
+	var widths [256]int
+	set := func(from int, to int, width int) {
+		for op := from; op <= to; op++ {
+			widths[op] = width
+		}
+	}
+
+	set(0x00, 0xff, 1)
+	set(0x02, 0x02, 2)
+	set(0x03, 0x03, 3)
+	set(0x05, 0x05, 2)
+	set(0x06, 0x06, 3)
+	set(0x08, 0x08, 2)
+	set(0x09, 0x09, 3)
+	set(0x13, 0x13, 2)
+	set(0x14, 0x14, 3)
+	set(0x15, 0x16, 2)
+	set(0x17, 0x17, 3)
+	set(0x18, 0x18, 5)
+	set(0x19, 0x1a, 2)
+	set(0x1b, 0x1b, 3)
+	set(0x1c, 0x1c, 2)
+	set(0x1f, 0x20, 2)
+	set(0x22, 0x23, 2)
+	set(0x24, 0x26, 3)
+	set(0x29, 0x29, 2)
+	set(0x2a, 0x2c, 3)
+	set(0x2d, 0x3d, 2)
+	set(0x44, 0x6d, 2)
+	set(0x6e, 0x72, 3)
+	set(0x74, 0x78, 3)
+	set(0x90, 0xaf, 2)
+	set(0xd0, 0xe2, 2)
+	set(0xfa, 0xfb, 4)
+	set(0xfc, 0xfd, 3)
+	set(0xfe, 0xff, 2)
+	return widths
+}()
+
+// DexReference is a method whose code loads a string
+type DexReference struct {
+	Class  string
+	Method string
+}
+
+// DexString is an entry of a dex string table with the methods that load it
+type DexString struct {
+	Value      string
+	References []DexReference
+}
+
+// dexReader reads little-endian values from a dex file. The first out of
+// bounds read is remembered in err and every later read returns zero.
+type dexReader struct {
+	data []byte
+	err  error
+}
+
+func (r *dexReader) check(offset int, size int) bool {
+	if r.err != nil {
+		return false
+	}
+	if offset < 0 || size < 0 || offset > len(r.data)-size {
+		r.err = fmt.Errorf("dex offset %#x out of bounds", offset)
+		return false
+	}
+	return true
+}
+
+func (r *dexReader) u16(offset int) int {
+	if !r.check(offset, 2) {
+		return 0
+	}
+	return int(binary.LittleEndian.Uint16(r.data[offset:]))
+}
+
+func (r *dexReader) u32(offset int) int {
+	if !r.check(offset, 4) {
+		return 0
+	}
+	return int(binary.LittleEndian.Uint32(r.data[offset:]))
+}
+
+// uleb128 decodes an unsigned LEB128 value and returns it with the offset after it
+func (r *dexReader) uleb128(offset int) (int, int) {
+	value := 0
+	for shift := 0; shift < 35; shift += 7 {
+		if !r.check(offset, 1) {
+			return 0, offset
+		}
+		b := r.data[offset]
+		offset++
+		value |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return value, offset
+		}
+	}
+	r.err = fmt.Errorf("dex uleb128 at %#x is too long", offset)
+	return 0, offset
+}
+
+// table returns the size and offset of a header table, checking that
+// size entries of itemSize bytes fit in the file
+func (r *dexReader) table(headerOffset int, itemSize int) (int, int) {
+	size, offset := r.u32(headerOffset), r.u32(headerOffset+4)
+	if size > 0 && (size > len(r.data)/itemSize) {
+		r.err = fmt.Errorf("dex table at header %#x is larger than the file", headerOffset)
+		return 0, 0
+	}
+	if size > 0 {
+		r.check(offset, size*itemSize)
+	}
+	return size, offset
+}
+
+// stringData decodes the string_data_item at offset
+func (r *dexReader) stringData(offset int) string {
+	_, start := r.uleb128(offset)
+	if r.err != nil {
+		return ""
+	}
+	end := start
+	for end < len(r.data) && r.data[end] != 0 {
+		end++
+	}
+	return decodeMUTF8(r.data[start:end])
+}
+
+// ParseDex reads the string table of a dex file and records, for every string,
+// the methods whose code loads it with const-string
+func ParseDex(data []byte) ([]DexString, error) {
+	if len(data) < dexHeaderSize || string(data[:4]) != "dex\n" {
+		return nil, fmt.Errorf("not a dex file")
+	}
+
+	r := &dexReader{data: data}
+	stringCount, stringIds := r.table(dexStringIdsSize, 4)
+	typeCount, typeIds := r.table(dexTypeIdsSize, 4)
+	methodCount, methodIds := r.table(dexMethodIdsSize, 8)
+	classCount, classDefs := r.table(dexClassDefsSize, dexClassDefLength)
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	strs := make([]DexString, stringCount)
+	for i := range strs {
+		strs[i].Value = r.stringData(r.u32(stringIds + 4*i))
+		if r.err != nil {
+			return nil, r.err
+		}
+	}
+
+	typeName := func(index int) string {
+		if index >= typeCount {
+			return ""
+		}
+		descriptor := r.u32(typeIds + 4*index)
+		if descriptor >= stringCount {
+			return ""
+		}
+		return descriptorToClassName(strs[descriptor].Value)
+	}
+	methodRef := func(index int) DexReference {
+		if index >= methodCount {
+			return DexReference{}
+		}
+		item := methodIds + 8*index
+		ref := DexReference{Class: typeName(r.u16(item))}
+		if name := r.u32(item + 4); name < stringCount {
+			ref.Method = strs[name].Value
+		}
+		return ref
+	}
+
+	type reference struct {
+		str    int
+		method int
+	}
+	seen := make(map[reference]bool)
+	for class := 0; class < classCount && r.err == nil; class++ {
+		classData := r.u32(classDefs + dexClassDefLength*class + 24)
+		if classData == 0 {
+			continue
+		}
+		r.visitMethods(classData, func(method int, code int) {
+			r.visitConstStrings(code, func(str int) {
+				if str >= stringCount || seen[reference{str, method}] {
+					return
+				}
+				seen[reference{str, method}] = true
+				strs[str].References = append(strs[str].References, methodRef(method))
+			})
+		})
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return strs, nil
+}
+
+// visitMethods calls visit with the method index and code offset of every
+// method with code in a class_data_item
+func (r *dexReader) visitMethods(offset int, visit func(method int, code int)) {
+	staticFields, offset := r.uleb128(offset)
+	instanceFields, offset := r.uleb128(offset)
+	directMethods, offset := r.uleb128(offset)
+	virtualMethods, offset := r.uleb128(offset)
+
+	for i := 0; i < staticFields+instanceFields && r.err == nil; i++ {
+		_, offset = r.uleb128(offset)
+		_, offset = r.uleb128(offset)
+	}
+
+	for _, count := range []int{directMethods, virtualMethods} {
+		method := 0
+		for i := 0; i < count && r.err == nil; i++ {
+			var diff, code int
+			diff, offset = r.uleb128(offset)
+			_, offset = r.uleb128(offset)
+			code, offset = r.uleb128(offset)
+			method += diff
+			if code != 0 {
+				visit(method, code)
+			}
+		}
+	}
+}
+
+// visitConstStrings walks the instructions of a code_item and calls visit
+// with the string index of every const-string and const-string/jumbo
+func (r *dexReader) visitConstStrings(offset int, visit func(str int)) {
+	units := r.u32(offset + 12)
+	pc := offset + 16
+	if !r.check(pc, 2*units) {
+		return
+	}
+
+	end := pc + 2*units
+	for pc < end && r.err == nil {
+		unit := r.u16(pc)
+		width := dexInstructionWidths[unit&0xff]
+		switch unit {
+		case packedSwitchPayload:
+			width = 4 + 2*r.u16(pc+2)
+		case sparseSwitchPayload:
+			width = 2 + 4*r.u16(pc+2)
+		case fillArrayDataPayload:
+			width = 4 + (r.u16(pc+2)*r.u32(pc+4)+1)/2
+		default:
+			switch unit & 0xff {
+			case opConstString:
+				visit(r.u16(pc + 2))
+			case opConstStringJumbo:
+				visit(r.u32(pc + 2))
+			}
+		}
+		pc += 2 * width
+	}
+}
+
+// decodeMUTF8 decodes the modified UTF-8 used by dex files, where characters
+// outside the BMP are stored as surrogate pairs and NUL as two bytes
+func decodeMUTF8(data []byte) string {
+	ascii := true
+	for _, b := range data {
+		if b >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return string(data)
+	}
+
+	units := make([]uint16, 0, len(data))
+	for i := 0; i < len(data); {
+		b := data[i]
+		switch {
+		case b < 0x80:
+			units = append(units, uint16(b))
+			i++
+		case b&0xe0 == 0xc0 && i+1 < len(data):
+			units = append(units, uint16(b&0x1f)<<6|uint16(data[i+1]&0x3f))
+			i += 2
+		case b&0xf0 == 0xe0 && i+2 < len(data):
+			units = append(units, uint16(b&0x0f)<<12|uint16(data[i+1]&0x3f)<<6|uint16(data[i+2]&0x3f))
+			i += 3
+		default:
+			units = append(units, 0xfffd)
+			i++
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// descriptorToClassName turns a type descriptor such as Lcom/example/Config;
+// into com.example.Config. Other descriptors are returned unchanged.
+func descriptorToClassName(descriptor string) string {
+	if strings.HasPrefix(descriptor, "L") && strings.HasSuffix(descriptor, ";") {
+		return strings.ReplaceAll(descriptor[1:len(descriptor)-1], "/", ".")
+	}
+	return descriptor
+}
@@ -0,0 +1,186 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"archive/zip"
+	"io"
+	"morf/models"
+	"regexp"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// dexFileRegex matches the dex files at the root of an APK
+var dexFileRegex = regexp.MustCompile(`^classes\d*\.dex$`)
+
+// StartSecretsOnlyScan scans the string tables of the dex files and native
+// libraries inside an APK. It needs neither apktool nor Java and reports
+// classes and methods instead of smali lines.
+func StartSecretsOnlyScan(apkPath string, patterns PatternSet) []models.SecretModel {
+	reader, err := zip.OpenReader(apkPath)
+	if err != nil {
+		log.Errorf("Unable to open %s: %v", apkPath, err)
+		return nil
+	}
+	defer reader.Close()
+
+	matcher := NewSecretMatcher(patterns.Patterns).EnableDecoding(LoadDecodeConfig())
+	analyzer := NewEntropyAnalyzer(LoadEntropyConfig())
+
+	embedded := matcher.newEmbeddedScanner(LoadArchiveLimits())
+
+	var findings, entropyFindings []models.SecretModel
+	var literals []string
+	for _, file := range reader.File {
+		if isNativeLibrary(file.Name) {
+			findings = append(findings, scanZippedNativeLibrary(matcher, file)...)
+			continue
+		}
+		if isEmbeddedFile(file.Name) {
+			findings = append(findings, scanZippedEmbeddedFile(embedded, file)...)
+			continue
+		}
+		if !dexFileRegex.MatchString(file.Name) {
+			continue
+		}
+
+		strs, err := readDexFile(file)
+		if err != nil {
+			log.Errorf("Unable to parse %s: %v", file.Name, err)
+			continue
+		}
+		log.Infof("Scanning %d strings from %s", len(strs), file.Name)
+
+		for _, str := range strs {
+			literals = append(literals, str.Value)
+		}
+		findings = append(findings, matcher.ScanDexStrings(file.Name, strs)...)
+		entropyFindings = append(entropyFindings, analyzer.ScanDexStrings(file.Name, strs)...)
+	}
+
+	findings = MergeEntropyFindings(findings, entropyFindings)
+	AnalyzeJWTs(findings, func() []string { return literals })
+	return SanitizeSecrets(findings)
+}
+
+// scanZippedNativeLibrary scans the strings of a shared library stored in an APK
+func scanZippedNativeLibrary(matcher *SecretMatcher, file *zip.File) []models.SecretModel {
+	content, err := file.Open()
+	if err != nil {
+		log.Debugf("Unable to open %s: %v", file.Name, err)
+		return nil
+	}
+	defer content.Close()
+
+	return matcher.scanNativeLibrary(file.Name, file.Name, content)
+}
+
+// scanZippedEmbeddedFile scans a file from assets/ or res/raw/ stored in an
+// APK, including its text since there is no decompiled copy to scan
+func scanZippedEmbeddedFile(embedded *embeddedScanner, file *zip.File) []models.SecretModel {
+	if file.FileInfo().IsDir() {
+		return nil
+	}
+
+	content, err := file.Open()
+	if err != nil {
+		log.Debugf("Unable to open %s: %v", file.Name, err)
+		return nil
+	}
+	defer content.Close()
+
+	return embedded.scanFile(file.Name, file.Name, content, true)
+}
+
+// readDexFile parses a dex file stored in an APK
+func readDexFile(file *zip.File) ([]DexString, error) {
+	if file.UncompressedSize64 > maxScanFileSize {
+		log.Warnf("Skipping %s: %d bytes is over the scan limit", file.Name, file.UncompressedSize64)
+		return nil, nil
+	}
+
+	content, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	data, err := io.ReadAll(io.LimitReader(content, maxScanFileSize))
+	if err != nil {
+		return nil, err
+	}
+	return ParseDex(data)
+}
+
+// ScanDexStrings matches the patterns against the string table of a dex file.
+// Every string is scanned as a quoted literal on a line of its own, the way
+// it appears in a smali const-string.
+func (m *SecretMatcher) ScanDexStrings(dexName string, strs []DexString) []models.SecretModel {
+	var content strings.Builder
+	for _, str := range strs {
+		content.WriteString(quoteLiteral(str.Value))
+		content.WriteByte('\n')
+	}
+
+	var findings []models.SecretModel
+	for _, finding := range m.scanContent(dexName, dexName, []byte(content.String())) {
+		findings = append(findings, dexFindings(finding, strs[finding.LineNo-1])...)
+	}
+	return findings
+}
+
+// ScanDexStrings scores every string in the string table of a dex file
+func (a *EntropyAnalyzer) ScanDexStrings(dexName string, strs []DexString) []models.SecretModel {
+	if !a.config.Enabled {
+		return nil
+	}
+
+	var findings []models.SecretModel
+	for _, str := range strs {
+		if finding, ok := a.Score(str.Value); ok {
+			finding.FileLocation = dexName
+			findings = append(findings, dexFindings(finding, str)...)
+		}
+	}
+	return findings
+}
+
+// dexFindings copies a finding for each method that loads its string. Line and
+// column positions are dropped since they only refer to the scanned listing.
+func dexFindings(finding models.SecretModel, str DexString) []models.SecretModel {
+	finding.LineNo, finding.ColumnNo = 0, 0
+	finding.EndLineNo, finding.EndColumnNo = 0, 0
+	if len(str.References) == 0 {
+		return []models.SecretModel{finding}
+	}
+
+	findings := make([]models.SecretModel, 0, len(str.References))
+	for _, ref := range str.References {
+		finding.ClassName = ref.Class
+		finding.MethodName = ref.Method
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// quoteLiteral renders a string as a quoted literal, the way smali shows constants
+func quoteLiteral(value string) string {
+	return strconv.Quote(value)
+}
@@ -0,0 +1,155 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"io"
+	"morf/models"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// elfStringSections are the sections of a shared library searched for strings
+var elfStringSections = []string{".rodata", ".data"}
+
+// minELFStringLength is the shortest run of printable bytes kept as a string
+const minELFStringLength = 8
+
+// ELFString is a run of printable characters in a data section of a shared library
+type ELFString struct {
+	Section string
+	Offset  uint64
+	Value   string
+}
+
+// ReadELFStrings extracts the printable strings from the data sections of an ELF file
+func ReadELFStrings(data []byte) ([]ELFString, error) {
+	file, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var strs []ELFString
+	for _, name := range elfStringSections {
+		section := file.Section(name)
+		if section == nil || section.Type == elf.SHT_NOBITS {
+			continue
+		}
+		content, err := section.Data()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", name, err)
+		}
+		strs = append(strs, printableStrings(name, content)...)
+	}
+	return strs, nil
+}
+
+// printableStrings returns the runs of printable ASCII in a section
+func printableStrings(section string, data []byte) []ELFString {
+	var strs []ELFString
+	start := -1
+	for i := 0; i <= len(data); i++ {
+		if i < len(data) && (data[i] >= 0x20 && data[i] < 0x7f || data[i] == '\t') {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && i-start >= minELFStringLength {
+			strs = append(strs, ELFString{Section: section, Offset: uint64(start), Value: string(data[start:i])})
+		}
+		start = -1
+	}
+	return strs
+}
+
+// isNativeLibrary reports whether a path relative to the APK root is a shared library
+func isNativeLibrary(relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
+	return strings.HasPrefix(relPath, "lib/") && strings.HasSuffix(relPath, ".so")
+}
+
+// ScanNativeLibraries matches the patterns against the strings of every
+// lib/<abi>/*.so under root
+func (m *SecretMatcher) ScanNativeLibraries(root string) []models.SecretModel {
+	var findings []models.SecretModel
+	walkFiles(filepath.Join(root, "lib"), func(path string) {
+		relPath, err := filepath.Rel(root, path)
+		if err != nil || !isNativeLibrary(relPath) {
+			return
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			log.Debugf("Unable to open %s: %v", path, err)
+			return
+		}
+		defer file.Close()
+
+		findings = append(findings, m.scanNativeLibrary(path, relPath, file)...)
+	})
+
+	sortFindings(findings)
+	return findings
+}
+
+// scanNativeLibrary reads a shared library and scans its strings
+func (m *SecretMatcher) scanNativeLibrary(path string, relPath string, reader io.Reader) []models.SecretModel {
+	data, err := io.ReadAll(io.LimitReader(reader, maxScanFileSize+1))
+	if err != nil {
+		log.Debugf("Unable to read %s: %v", path, err)
+		return nil
+	}
+	if len(data) > maxScanFileSize {
+		log.Warnf("Skipping %s: over the scan limit", path)
+		return nil
+	}
+
+	strs, err := ReadELFStrings(data)
+	if err != nil {
+		log.Debugf("Skipping %s: %v", path, err)
+		return nil
+	}
+	return m.ScanNativeStrings(path, relPath, strs)
+}
+
+// ScanNativeStrings matches the patterns against strings from a shared library,
+// each scanned as a quoted literal on a line of its own
+func (m *SecretMatcher) ScanNativeStrings(path string, relPath string, strs []ELFString) []models.SecretModel {
+	var content strings.Builder
+	for _, str := range strs {
+		content.WriteString(quoteLiteral(str.Value))
+		content.WriteByte('\n')
+	}
+
+	findings := m.scanContent(path, relPath, []byte(content.String()))
+	for i := range findings {
+		str := strs[findings[i].LineNo-1]
+		findings[i].Section = str.Section
+		findings[i].Offset = str.Offset
+		findings[i].LineNo, findings[i].ColumnNo = 0, 0
+		findings[i].EndLineNo, findings[i].EndColumnNo = 0, 0
+	}
+	return findings
+}
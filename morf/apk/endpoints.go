@@ -0,0 +1,347 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"html"
+	"morf/models"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxEndpointLocations caps the locations kept for one endpoint, as the same
+// URL is often repeated in every generated class
+const maxEndpointLocations = 50
+
+var (
+	endpointURLRegex = regexp.MustCompile(`(?i)\b(?:https?|wss?|ftp|s3|gs)://[A-Za-z0-9\-._~:/?#\[\]@!$&()*+,;=%]+`)
+	// Quoted literals and XML element text, which may hold a bare host or IP
+	endpointLiteralRegex = regexp.MustCompile(`"([^"\\\n]{4,253})"|>([^<>\n]{4,253})<`)
+	hostnameRegex        = regexp.MustCompile(`^(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+	ipv4Regex            = regexp.MustCompile(`^\d{1,3}(?:\.\d{1,3}){3}$`)
+
+	s3VirtualHostRegex  = regexp.MustCompile(`^([a-z0-9][a-z0-9.-]*)\.s3[.-](?:[a-z0-9-]+\.)*amazonaws\.com$`)
+	s3PathHostRegex     = regexp.MustCompile(`^s3(?:[.-][a-z0-9-]+)*\.amazonaws\.com$`)
+	gcsVirtualHostRegex = regexp.MustCompile(`^([a-z0-9][a-z0-9._-]*)\.storage\.googleapis\.com$`)
+	azureBlobHostRegex  = regexp.MustCompile(`^([a-z0-9]+)\.blob\.core\.windows\.net$`)
+)
+
+// ignoredEndpointHosts are XML namespace hosts present in nearly every APK
+var ignoredEndpointHosts = map[string]bool{
+	"schemas.android.com":   true,
+	"schemas.xmlsoap.org":   true,
+	"www.w3.org":            true,
+	"ns.adobe.com":          true,
+	"xmlpull.org":           true,
+	"www.xmlpull.org":       true,
+	"xml.org":               true,
+	"xml.apache.org":        true,
+	"java.sun.com":          true,
+	"purl.org":              true,
+	"ns.android.com":        true,
+	"schemas.microsoft.com": true,
+}
+
+var cleartextSchemes = map[string]bool{"http": true, "ws": true, "ftp": true}
+
+// Bare literals are only taken for hosts when they end in one of these, or in
+// a country code with at least three labels, to avoid dotted identifiers
+var knownTLDs = map[string]bool{
+	"com": true, "net": true, "org": true, "edu": true, "gov": true, "mil": true, "int": true,
+	"io": true, "co": true, "me": true, "ai": true, "tv": true, "ly": true, "gg": true,
+	"app": true, "dev": true, "cloud": true, "info": true, "biz": true, "xyz": true,
+	"online": true, "site": true, "tech": true, "store": true, "local": true, "internal": true,
+	"lan": true, "corp": true, "intranet": true, "localdomain": true,
+}
+
+// Leading labels of Java package names, which look like reversed hosts
+var packagePrefixes = map[string]bool{
+	"com": true, "org": true, "net": true, "io": true, "dev": true, "android": true, "androidx": true,
+	"java": true, "javax": true, "kotlin": true, "kotlinx": true, "dalvik": true, "okhttp3": true,
+	"retrofit2": true,
+}
+
+// Two-letter file extensions that would otherwise pass as country codes
+var fileExtensions = map[string]bool{"js": true, "so": true, "db": true, "gz": true, "md": true, "py": true, "sh": true, "ts": true}
+
+var internalHostSuffixes = []string{".local", ".internal", ".lan", ".corp", ".intranet", ".localdomain", ".home.arpa"}
+
+// environmentTokens maps host labels to the environment they hint at
+var environmentTokens = map[string]string{
+	"staging": "staging", "stage": "staging", "stg": "staging", "preprod": "staging",
+	"dev": "dev", "develop": "dev", "development": "dev", "sandbox": "dev",
+	"qa": "qa", "test": "qa", "testing": "qa", "uat": "qa",
+}
+
+// endpointExtractor collects endpoints keyed by their value
+type endpointExtractor struct {
+	endpoints map[string]*models.Endpoint
+}
+
+// ExtractEndpoints collects the URLs, hosts, IP addresses and cloud storage
+// buckets referenced by the smali sources and assets under sourceDir and by
+// the decoded resources under resDir
+func ExtractEndpoints(sourceDir, resDir string) models.EndpointArray {
+	extractor := &endpointExtractor{endpoints: make(map[string]*models.Endpoint)}
+
+	walkFiles(sourceDir, func(path string) {
+		relPath, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return
+		}
+		top := strings.SplitN(filepath.ToSlash(relPath), "/", 2)[0]
+		if strings.HasPrefix(top, "smali") || top == "assets" {
+			extractor.scanFile(path)
+		}
+	})
+	walkFiles(filepath.Join(resDir, "res"), extractor.scanFile)
+	if _, err := os.Stat(filepath.Join(resDir, "AndroidManifest.xml")); err == nil {
+		extractor.scanFile(filepath.Join(resDir, "AndroidManifest.xml"))
+	}
+
+	endpoints := make(models.EndpointArray, 0, len(extractor.endpoints))
+	for _, endpoint := range extractor.endpoints {
+		endpoints = append(endpoints, *endpoint)
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].Value < endpoints[j].Value })
+	return endpoints
+}
+
+func (x *endpointExtractor) scanFile(path string) {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxScanFileSize {
+		return
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		log.Debugf("Unable to read %s: %v", path, err)
+		return
+	}
+
+	text := string(content)
+	isXML := strings.HasSuffix(path, ".xml")
+	lines := newLineIndex(content)
+
+	for _, loc := range endpointURLRegex.FindAllStringIndex(text, -1) {
+		raw := strings.TrimRight(text[loc[0]:loc[1]], ".,;:!?)]*'")
+		if isXML {
+			raw = html.UnescapeString(raw)
+		}
+		if endpoint, ok := classifyURL(raw); ok {
+			lineNo, _ := lines.position(loc[0])
+			x.add(endpoint, path, lineNo)
+		}
+	}
+
+	for _, loc := range endpointLiteralRegex.FindAllStringSubmatchIndex(text, -1) {
+		start, end := loc[2], loc[3]
+		if start < 0 {
+			start, end = loc[4], loc[5]
+		}
+		literal := strings.TrimSpace(text[start:end])
+		if isXML {
+			literal = html.UnescapeString(literal)
+		}
+		if endpoint, ok := classifyLiteral(literal); ok {
+			lineNo, _ := lines.position(start)
+			x.add(endpoint, path, lineNo)
+		}
+	}
+}
+
+func (x *endpointExtractor) add(endpoint models.Endpoint, path string, lineNo int) {
+	existing, ok := x.endpoints[endpoint.Value]
+	if !ok {
+		existing = &endpoint
+		x.endpoints[endpoint.Value] = existing
+	}
+	if len(existing.Locations) < maxEndpointLocations {
+		existing.Locations = append(existing.Locations, models.EndpointLocation{FileLocation: path, LineNo: lineNo})
+	}
+}
+
+// classifyURL builds an endpoint from a URL, or reports false when it has no
+// host or points at an XML namespace
+func classifyURL(raw string) (models.Endpoint, bool) {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Hostname() == "" {
+		return models.Endpoint{}, false
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	if ignoredEndpointHosts[host] {
+		return models.Endpoint{}, false
+	}
+
+	scheme := strings.ToLower(parsed.Scheme)
+	endpoint := models.Endpoint{
+		Value:     stripURLSecrets(parsed),
+		Type:      models.EndpointURL,
+		Scheme:    scheme,
+		Host:      host,
+		Cleartext: cleartextSchemes[scheme],
+	}
+
+	switch scheme {
+	case "s3":
+		endpoint.Type, endpoint.Provider, endpoint.Bucket = models.EndpointBucket, "s3", host
+	case "gs":
+		endpoint.Type, endpoint.Provider, endpoint.Bucket = models.EndpointBucket, "gcs", host
+	default:
+		classifyHost(&endpoint, host, parsed.Path)
+	}
+	endpoint.Internal = isInternalHost(host)
+	endpoint.Environment = environmentOf(host)
+	return endpoint, true
+}
+
+// stripURLSecrets drops the query parameter values and any password of a URL,
+// which often carry API keys such as ?key=AIza...
+func stripURLSecrets(parsed *url.URL) string {
+	stripped := *parsed
+	if password, ok := parsed.User.Password(); ok && password != "" {
+		stripped.User = url.User(parsed.User.Username())
+	}
+	if parsed.RawQuery != "" {
+		params := strings.Split(parsed.RawQuery, "&")
+		for i, param := range params {
+			if name, _, found := strings.Cut(param, "="); found {
+				params[i] = name + "="
+			}
+		}
+		stripped.RawQuery = strings.Join(params, "&")
+	}
+	return stripped.String()
+}
+
+// classifyLiteral builds an endpoint from a string literal that is exactly a
+// host name or IPv4 address, optionally followed by a port
+func classifyLiteral(literal string) (models.Endpoint, bool) {
+	host := literal
+	if h, port, err := net.SplitHostPort(literal); err == nil && port != "" {
+		host = h
+	}
+
+	endpoint := models.Endpoint{Value: literal, Host: host}
+	switch {
+	case ipv4Regex.MatchString(host) && net.ParseIP(host) != nil:
+		endpoint.Type = models.EndpointIP
+	case isHostLiteral(host):
+		endpoint.Type = models.EndpointHost
+		classifyHost(&endpoint, host, "")
+	default:
+		return models.Endpoint{}, false
+	}
+	if ignoredEndpointHosts[host] {
+		return models.Endpoint{}, false
+	}
+
+	endpoint.Internal = isInternalHost(host)
+	endpoint.Environment = environmentOf(host)
+	return endpoint, true
+}
+
+// isHostLiteral reports whether a lowercase dotted literal looks like a host
+// rather than a package, class or file name
+func isHostLiteral(literal string) bool {
+	if !hostnameRegex.MatchString(literal) {
+		return false
+	}
+
+	labels := strings.Split(literal, ".")
+	if packagePrefixes[labels[0]] {
+		return false
+	}
+
+	tld := labels[len(labels)-1]
+	if knownTLDs[tld] {
+		return true
+	}
+	return len(tld) == 2 && len(labels) >= 3 && !fileExtensions[tld]
+}
+
+// classifyHost marks endpoints on cloud storage hosts as buckets
+func classifyHost(endpoint *models.Endpoint, host, path string) {
+	firstSegment := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)[0]
+
+	var provider, bucket string
+	switch {
+	case s3VirtualHostRegex.MatchString(host):
+		provider, bucket = "s3", s3VirtualHostRegex.FindStringSubmatch(host)[1]
+	case s3PathHostRegex.MatchString(host):
+		provider, bucket = "s3", firstSegment
+	case gcsVirtualHostRegex.MatchString(host):
+		provider, bucket = "gcs", gcsVirtualHostRegex.FindStringSubmatch(host)[1]
+	case host == "storage.googleapis.com":
+		provider, bucket = "gcs", firstSegment
+	case host == "firebasestorage.googleapis.com":
+		// Firebase Storage paths look like /v0/b/<bucket>/o/<object>
+		if parts := strings.Split(strings.TrimPrefix(path, "/"), "/"); len(parts) >= 3 && parts[1] == "b" {
+			provider, bucket = "gcs", parts[2]
+		}
+	case azureBlobHostRegex.MatchString(host):
+		provider, bucket = "azure", azureBlobHostRegex.FindStringSubmatch(host)[1]
+		if firstSegment != "" {
+			bucket += "/" + firstSegment
+		}
+	}
+
+	if provider != "" && bucket != "" {
+		endpoint.Type = models.EndpointBucket
+		endpoint.Provider = provider
+		endpoint.Bucket = bucket
+	}
+}
+
+// isInternalHost reports whether a host is private, loopback or only
+// resolvable inside a corporate network
+func isInternalHost(host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified()
+	}
+	if host == "localhost" || !strings.Contains(host, ".") {
+		return true
+	}
+	for _, suffix := range internalHostSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// environmentOf returns the environment hinted at by the labels of a host,
+// such as staging for api-stg2.example.com
+func environmentOf(host string) string {
+	if net.ParseIP(host) != nil {
+		return ""
+	}
+	tokens := strings.FieldsFunc(host, func(r rune) bool { return r == '.' || r == '-' || r == '_' })
+	for _, token := range tokens {
+		if environment, ok := environmentTokens[strings.TrimRight(token, "0123456789")]; ok {
+			return environment
+		}
+	}
+	return ""
+}
@@ -0,0 +1,291 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"bufio"
+	"io/fs"
+	"math"
+	"morf/models"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	vip "github.com/spf13/viper"
+)
+
+// EntropyFindingType is the Type given to secrets found by the entropy analyzer
+const EntropyFindingType = "High Entropy String"
+
+// Charset names used by the entropy analyzer
+const (
+	CharsetBase64       = "base64"
+	CharsetHex          = "hex"
+	CharsetAlphanumeric = "alphanumeric"
+)
+
+var (
+	constStringRegex  = regexp.MustCompile(`const-string(?:/jumbo)?\s+[vp]\d+,\s+"((?:[^"\\]|\\.)*)"`)
+	xmlStringRegex    = regexp.MustCompile(`<string\s+name="([^"]+)"[^>]*>([^<]*)</string>`)
+	hexCharset        = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+	alphanumCharset   = regexp.MustCompile(`^[0-9a-zA-Z]+$`)
+	base64Charset     = regexp.MustCompile(`^[0-9a-zA-Z+/_\-]+={0,2}$`)
+	containsDigit     = regexp.MustCompile(`[0-9]`)
+	containsLetter    = regexp.MustCompile(`[a-zA-Z]`)
+	containsUpperCase = regexp.MustCompile(`[A-Z]`)
+	containsLowerCase = regexp.MustCompile(`[a-z]`)
+)
+
+// minOverlapLength is the shortest regex secret considered when deduplicating entropy hits
+const minOverlapLength = 8
+
+// EntropyThreshold bounds which strings of a charset are reported
+type EntropyThreshold struct {
+	Threshold float64
+	MinLength int
+	MaxLength int
+}
+
+// EntropyConfig holds the per-charset thresholds used by the entropy analyzer
+type EntropyConfig struct {
+	Enabled      bool
+	Base64       EntropyThreshold
+	Hex          EntropyThreshold
+	Alphanumeric EntropyThreshold
+}
+
+// DefaultEntropyConfig returns the built-in entropy thresholds
+func DefaultEntropyConfig() EntropyConfig {
+	return EntropyConfig{
+		Enabled:      true,
+		Base64:       EntropyThreshold{Threshold: 4.2, MinLength: 24, MaxLength: 200},
+		Hex:          EntropyThreshold{Threshold: 3.0, MinLength: 24, MaxLength: 128},
+		Alphanumeric: EntropyThreshold{Threshold: 3.8, MinLength: 20, MaxLength: 100},
+	}
+}
+
+// LoadEntropyConfig applies any entropy.* settings from viper on top of the defaults
+func LoadEntropyConfig() EntropyConfig {
+	config := DefaultEntropyConfig()
+	if vip.IsSet("entropy.enabled") {
+		config.Enabled = vip.GetBool("entropy.enabled")
+	}
+	loadEntropyThreshold("entropy."+CharsetBase64, &config.Base64)
+	loadEntropyThreshold("entropy."+CharsetHex, &config.Hex)
+	loadEntropyThreshold("entropy."+CharsetAlphanumeric, &config.Alphanumeric)
+	return config
+}
+
+func loadEntropyThreshold(prefix string, threshold *EntropyThreshold) {
+	if vip.IsSet(prefix + ".threshold") {
+		threshold.Threshold = vip.GetFloat64(prefix + ".threshold")
+	}
+	if vip.IsSet(prefix + ".min_length") {
+		threshold.MinLength = vip.GetInt(prefix + ".min_length")
+	}
+	if vip.IsSet(prefix + ".max_length") {
+		threshold.MaxLength = vip.GetInt(prefix + ".max_length")
+	}
+}
+
+// EntropyAnalyzer reports string literals whose randomness suggests a secret
+type EntropyAnalyzer struct {
+	config EntropyConfig
+}
+
+// NewEntropyAnalyzer creates a new instance of EntropyAnalyzer
+func NewEntropyAnalyzer(config EntropyConfig) *EntropyAnalyzer {
+	return &EntropyAnalyzer{config: config}
+}
+
+// ScanSources scores const-string literals in smali files under sourceDir and
+// string resources in res/values*/*.xml under resDir
+func (a *EntropyAnalyzer) ScanSources(sourceDir string, resDir string) []models.SecretModel {
+	if !a.config.Enabled {
+		return nil
+	}
+
+	var findings []models.SecretModel
+	walkFiles(sourceDir, func(path string) {
+		if strings.HasSuffix(path, ".smali") {
+			findings = append(findings, a.scanFile(path, constStringRegex, 1)...)
+		}
+	})
+	walkFiles(resDir, func(path string) {
+		if strings.HasSuffix(path, ".xml") && strings.HasPrefix(filepath.Base(filepath.Dir(path)), "values") {
+			findings = append(findings, a.scanFile(path, xmlStringRegex, 2)...)
+		}
+	})
+
+	sortFindings(findings)
+	log.Infof("Entropy analysis found %d candidate secrets", len(findings))
+	return findings
+}
+
+// scanFile scores the literal captured by group of every match of literalRegex in a file
+func (a *EntropyAnalyzer) scanFile(path string, literalRegex *regexp.Regexp, group int) []models.SecretModel {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Debugf("Unable to open %s: %v", path, err)
+		return nil
+	}
+	defer file.Close()
+
+	var findings []models.SecretModel
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxScanFileSize)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		for _, match := range literalRegex.FindAllStringSubmatchIndex(scanner.Text(), -1) {
+			literal := scanner.Text()[match[2*group]:match[2*group+1]]
+			if finding, ok := a.Score(literal); ok {
+				finding.FileLocation = path
+				finding.LineNo = lineNo
+				finding.ColumnNo = match[2*group] + 1
+				finding.EndLineNo = lineNo
+				finding.EndColumnNo = match[2*group+1] + 1
+				findings = append(findings, finding)
+			}
+		}
+	}
+	return findings
+}
+
+// Score classifies a literal by charset and returns a finding when its entropy
+// clears the threshold configured for that charset
+func (a *EntropyAnalyzer) Score(literal string) (models.SecretModel, bool) {
+	literal = strings.TrimSpace(literal)
+	charset, threshold := a.classify(literal)
+	if charset == "" {
+		return models.SecretModel{}, false
+	}
+	if len(literal) < threshold.MinLength || len(literal) > threshold.MaxLength {
+		return models.SecretModel{}, false
+	}
+
+	entropy := ShannonEntropy(literal)
+	if entropy < threshold.Threshold {
+		return models.SecretModel{}, false
+	}
+
+	return models.SecretModel{
+		Type:             EntropyFindingType,
+		SecretType:       EntropyFindingType + " (" + charset + ")",
+		SecretString:     literal,
+		SecretConfidence: "low",
+		Entropy:          math.Round(entropy*1000) / 1000,
+		Severity:         "low",
+		CWE:              "CWE-798",
+		Description:      "String literal with unusually high randomness for its charset, possibly a hardcoded credential",
+		Remediation:      "Confirm whether the value is a credential; if so, move it out of the APK and rotate it",
+	}, true
+}
+
+// classify picks the narrowest charset a literal belongs to. Literals without
+// the character mix of a generated token (e.g. plain words) are rejected.
+func (a *EntropyAnalyzer) classify(literal string) (string, EntropyThreshold) {
+	switch {
+	case hexCharset.MatchString(literal) && containsDigit.MatchString(literal) && containsLetter.MatchString(literal):
+		return CharsetHex, a.config.Hex
+	case alphanumCharset.MatchString(literal) && containsDigit.MatchString(literal) &&
+		containsUpperCase.MatchString(literal) && containsLowerCase.MatchString(literal):
+		return CharsetAlphanumeric, a.config.Alphanumeric
+	case base64Charset.MatchString(literal) && containsDigit.MatchString(literal) && containsLetter.MatchString(literal):
+		return CharsetBase64, a.config.Base64
+	}
+	return "", EntropyThreshold{}
+}
+
+// ShannonEntropy returns the Shannon entropy of a string in bits per character
+func ShannonEntropy(value string) float64 {
+	if value == "" {
+		return 0
+	}
+
+	frequencies := make(map[rune]int)
+	total := 0
+	for _, r := range value {
+		frequencies[r]++
+		total++
+	}
+
+	entropy := 0.0
+	for _, count := range frequencies {
+		probability := float64(count) / float64(total)
+		entropy -= probability * math.Log2(probability)
+	}
+	return entropy
+}
+
+// MergeEntropyFindings appends entropy hits that were not already reported by a
+// regex pattern, either at the same location or as part of the same secret
+func MergeEntropyFindings(regexHits []models.SecretModel, entropyHits []models.SecretModel) []models.SecretModel {
+	type location struct {
+		file string
+		line int
+	}
+
+	// Findings without a line, such as dex strings, are only matched by value
+	seenLocations := make(map[location]bool)
+	for _, hit := range regexHits {
+		if hit.LineNo > 0 {
+			seenLocations[location{hit.FileLocation, hit.LineNo}] = true
+		}
+	}
+
+	merged := regexHits
+	for _, hit := range entropyHits {
+		if (hit.LineNo > 0 && seenLocations[location{hit.FileLocation, hit.LineNo}]) || coveredByRegexHit(hit.SecretString, regexHits) {
+			continue
+		}
+		merged = append(merged, hit)
+	}
+	return merged
+}
+
+// coveredByRegexHit reports whether a literal overlaps a secret already found by a pattern
+func coveredByRegexHit(literal string, regexHits []models.SecretModel) bool {
+	for _, hit := range regexHits {
+		if len(hit.SecretString) < minOverlapLength {
+			continue
+		}
+		if strings.Contains(hit.SecretString, literal) || strings.Contains(literal, hit.SecretString) {
+			return true
+		}
+	}
+	return false
+}
+
+// walkFiles calls visit for every regular file under root
+func walkFiles(root string, visit func(path string)) {
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			log.Debugf("Skipping %s: %v", path, err)
+			return nil
+		}
+		if entry.Type().IsRegular() {
+			visit(path)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Error walking %s: %v", root, err)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"morf/models"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEntropyScore(t *testing.T) {
+	analyzer := NewEntropyAnalyzer(DefaultEntropyConfig())
+
+	tests := []struct {
+		name        string
+		literal     string
+		wantFound   bool
+		wantCharset string
+	}{
+		{"Hex key", "9f86d081884c7d659a2feaa0c55ad015", true, "hex"},
+		{"Alphanumeric token", "q8ZkX2vN7bR4tW1mYc5Lp0Hs", true, "alphanumeric"},
+		{"Base64 secret", "dGhpcyBpcyBhIHZlcnkgc2VjcmV0IGtleSE=", true, "base64"},
+		{"Plain word", "thisisjustaverylongidentifier", false, ""},
+		{"Class name", "com.example.app.MainActivity", false, ""},
+		{"Low entropy hex", "0000000000000000000000000000000a", false, ""},
+		{"Too short", "a1B2c3D4", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			finding, found := analyzer.Score(tt.literal)
+			if found != tt.wantFound {
+				t.Fatalf("Score(%q) found=%v (entropy %.2f), want %v", tt.literal, found, ShannonEntropy(tt.literal), tt.wantFound)
+			}
+			if found && finding.SecretType != EntropyFindingType+" ("+tt.wantCharset+")" {
+				t.Errorf("Score(%q) secretType=%s, want charset %s", tt.literal, finding.SecretType, tt.wantCharset)
+			}
+		})
+	}
+}
+
+func TestEntropyScanSourcesDeduplicates(t *testing.T) {
+	sourceDir := t.TempDir()
+	resDir := t.TempDir()
+
+	smali := filepath.Join(sourceDir, "smali", "Keys.smali")
+	stringsXML := filepath.Join(resDir, "res", "values", "strings.xml")
+	os.MkdirAll(filepath.Dir(smali), 0755)
+	os.MkdirAll(filepath.Dir(stringsXML), 0755)
+	os.WriteFile(smali, []byte("    const-string v0, \"9f86d081884c7d659a2feaa0c55ad015\"\n    const-string v1, \"hello world\"\n"), 0644)
+	os.WriteFile(stringsXML, []byte("<resources>\n    <string name=\"token\">q8ZkX2vN7bR4tW1mYc5Lp0Hs</string>\n</resources>\n"), 0644)
+
+	entropyHits := NewEntropyAnalyzer(DefaultEntropyConfig()).ScanSources(sourceDir, resDir)
+	if len(entropyHits) != 2 {
+		t.Fatalf("Expected 2 entropy hits, got %d", len(entropyHits))
+	}
+
+	regexHits := []models.SecretModel{{Type: "Generic Hex Key", FileLocation: smali, LineNo: 1, SecretString: "9f86d081884c7d659a2feaa0c55ad015"}}
+	merged := MergeEntropyFindings(regexHits, entropyHits)
+	if len(merged) != 2 {
+		t.Fatalf("Expected regex hit plus one entropy hit, got %d findings", len(merged))
+	}
+	if merged[1].FileLocation != stringsXML || merged[1].LineNo != 2 {
+		t.Errorf("Unexpected surviving entropy hit: %+v", merged[1])
+	}
+}
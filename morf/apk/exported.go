@@ -0,0 +1,129 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"fmt"
+	"morf/models"
+	"strconv"
+)
+
+// exportDecision is whether a component is exported, and why
+type exportDecision struct {
+	Exported bool
+	Reason   string
+}
+
+// exportRule decides the exported state of a component, or returns false
+// when it does not apply so that the next rule is tried
+type exportRule func(component *XMLElement, targetSdk int) (exportDecision, bool)
+
+// exportRules mirror how the package manager resolves android:exported.
+// They are tried in order and the first one that applies decides.
+var exportRules = []exportRule{
+	explicitExportRule,
+	providerDefaultExportRule,
+	undeclaredIntentFilterExportRule,
+	intentFilterExportRule,
+	defaultExportRule,
+}
+
+// componentExportDecision runs the export rules on an activity,
+// activity-alias, service, receiver or provider element
+func componentExportDecision(component *XMLElement, targetSdk int) exportDecision {
+	for _, rule := range exportRules {
+		if decision, ok := rule(component, targetSdk); ok {
+			return decision
+		}
+	}
+	return exportDecision{}
+}
+
+// explicitExportRule honours android:exported whenever it is set
+func explicitExportRule(component *XMLElement, targetSdk int) (exportDecision, bool) {
+	exported, ok := component.AndroidAttr("exported").Bool()
+	if !ok {
+		return exportDecision{}, false
+	}
+	return exportDecision{exported, fmt.Sprintf("android:exported is %v", exported)}, true
+}
+
+// providerDefaultExportRule defaults providers, which ignore intent filters,
+// to exported below Android 4.2
+func providerDefaultExportRule(component *XMLElement, targetSdk int) (exportDecision, bool) {
+	if component.Name != "provider" {
+		return exportDecision{}, false
+	}
+	if targetSdk < sdkJellyBeanMR1 {
+		return exportDecision{true, fmt.Sprintf("providers are exported by default below targetSdk %d (targetSdk %d)", sdkJellyBeanMR1, targetSdk)}, true
+	}
+	return exportDecision{false, fmt.Sprintf("providers are not exported by default from targetSdk %d (targetSdk %d)", sdkJellyBeanMR1, targetSdk)}, true
+}
+
+// undeclaredIntentFilterExportRule covers Android 12, which refuses to
+// install apps whose components have intent filters but no android:exported
+func undeclaredIntentFilterExportRule(component *XMLElement, targetSdk int) (exportDecision, bool) {
+	if targetSdk < sdkS || !hasIntentFilters(component) {
+		return exportDecision{}, false
+	}
+	return exportDecision{false, fmt.Sprintf("has intent filters without android:exported, which the platform refuses to install from targetSdk %d (targetSdk %d)", sdkS, targetSdk)}, true
+}
+
+// intentFilterExportRule exports components that declare intent filters
+func intentFilterExportRule(component *XMLElement, targetSdk int) (exportDecision, bool) {
+	if !hasIntentFilters(component) {
+		return exportDecision{}, false
+	}
+	return exportDecision{true, "has intent filters and no android:exported"}, true
+}
+
+// defaultExportRule keeps every other component private
+func defaultExportRule(component *XMLElement, targetSdk int) (exportDecision, bool) {
+	return exportDecision{false, "has no intent filters and no android:exported"}, true
+}
+
+// hasIntentFilters reports whether a component has an intent filter with
+// an action. The package manager drops filters without actions.
+func hasIntentFilters(component *XMLElement) bool {
+	for _, filter := range component.ChildrenNamed("intent-filter") {
+		if len(filter.ChildrenNamed("action")) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// effectiveTargetSdk returns the target SDK the platform applies to the app.
+// An absent targetSdkVersion defaults to minSdkVersion, which defaults to 1.
+func effectiveTargetSdk(manifest *XMLElement, metadata *models.MetaDataModel) int {
+	usesSdk := manifest.Child("uses-sdk")
+	for _, value := range []struct {
+		attr     string
+		metadata string
+	}{
+		{"targetSdkVersion", metadata.AndroidManifest.UsesTargetSdkVersion},
+		{"minSdkVersion", metadata.AndroidManifest.UsesMinSdkVersion},
+	} {
+		if sdk, ok := usesSdk.AndroidAttr(value.attr).Int(); ok && sdk > 0 {
+			return sdk
+		}
+		if sdk, err := strconv.Atoi(value.metadata); err == nil && sdk > 0 {
+			return sdk
+		}
+	}
+	return 1
+}
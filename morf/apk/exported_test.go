@@ -0,0 +1,94 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"morf/models"
+	"testing"
+)
+
+func TestComponentExportDecision(t *testing.T) {
+	exported := func(value bool) XMLAttribute {
+		if value {
+			return testAttr("exported", 0x01010010, AttrTypeIntBoolean, 0xffffffff, "true")
+		}
+		return testAttr("exported", 0x01010010, AttrTypeIntBoolean, 0, "false")
+	}
+	filter := func(actions ...string) *XMLElement {
+		element := &XMLElement{Name: "intent-filter"}
+		for _, action := range actions {
+			element.Children = append(element.Children, &XMLElement{Name: "action", Attributes: []XMLAttribute{
+				testAttr("name", 0x01010003, AttrTypeString, 0, action),
+			}})
+		}
+		return element
+	}
+	grant := testAttr("grantUriPermissions", 0x0101001b, AttrTypeIntBoolean, 0xffffffff, "true")
+
+	tests := []struct {
+		name      string
+		component *XMLElement
+		targetSdk int
+		want      bool
+	}{
+		{"explicit exported", &XMLElement{Name: "service", Attributes: []XMLAttribute{exported(true)}}, 34, true},
+		{"explicit not exported with filter", &XMLElement{Name: "receiver", Attributes: []XMLAttribute{exported(false)}, Children: []*XMLElement{filter("a")}}, 30, false},
+		{"intent filter", &XMLElement{Name: "activity", Children: []*XMLElement{filter("a")}}, 30, true},
+		{"intent filter without actions", &XMLElement{Name: "activity", Children: []*XMLElement{filter()}}, 30, false},
+		{"undeclared intent filter on targetSdk 31", &XMLElement{Name: "service", Children: []*XMLElement{filter("a")}}, 31, false},
+		{"activity-alias with intent filter", &XMLElement{Name: "activity-alias", Children: []*XMLElement{filter("a")}}, 28, true},
+		{"no intent filter", &XMLElement{Name: "receiver"}, 16, false},
+		{"provider below targetSdk 17", &XMLElement{Name: "provider"}, 16, true},
+		{"provider from targetSdk 17", &XMLElement{Name: "provider", Children: []*XMLElement{filter("a")}}, 17, false},
+		{"provider with grantUriPermissions", &XMLElement{Name: "provider", Attributes: []XMLAttribute{grant}}, 34, false},
+		{"provider explicitly exported", &XMLElement{Name: "provider", Attributes: []XMLAttribute{exported(true)}}, 34, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := componentExportDecision(tt.component, tt.targetSdk)
+			if decision.Exported != tt.want {
+				t.Errorf("got exported=%v (%s), want %v", decision.Exported, decision.Reason, tt.want)
+			}
+			if decision.Reason == "" {
+				t.Error("missing reason")
+			}
+		})
+	}
+}
+
+func TestEffectiveTargetSdk(t *testing.T) {
+	usesSdk := func(attrs ...XMLAttribute) *XMLElement {
+		return &XMLElement{Name: "manifest", Children: []*XMLElement{{Name: "uses-sdk", Attributes: attrs}}}
+	}
+	minSdk := testAttr("minSdkVersion", 0x0101020c, AttrTypeIntDec, 15, "15")
+	targetSdk := testAttr("targetSdkVersion", 0x01010270, AttrTypeIntDec, 33, "33")
+
+	var metadata models.MetaDataModel
+	if got := effectiveTargetSdk(usesSdk(minSdk, targetSdk), &metadata); got != 33 {
+		t.Errorf("got %d, want the targetSdkVersion 33", got)
+	}
+	if got := effectiveTargetSdk(usesSdk(minSdk), &metadata); got != 15 {
+		t.Errorf("got %d, want the minSdkVersion 15", got)
+	}
+	if got := effectiveTargetSdk(usesSdk(), &metadata); got != 1 {
+		t.Errorf("got %d, want 1", got)
+	}
+	metadata.AndroidManifest.UsesTargetSdkVersion = "29"
+	if got := effectiveTargetSdk(usesSdk(minSdk), &metadata); got != 29 {
+		t.Errorf("got %d, want the metadata targetSdk 29", got)
+	}
+}
@@ -0,0 +1,228 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"fmt"
+	"html"
+	"morf/models"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	vip "github.com/spf13/viper"
+)
+
+// Default endpoints probed by the Firebase checks
+const (
+	defaultFirebaseStorageURL = "https://firebasestorage.googleapis.com"
+	defaultFirebaseTimeout    = 10 * time.Second
+)
+
+// firebaseDatabaseHosts are the domains a Realtime Database URL may point to.
+// Any other URL read from the APK is not requested, so a crafted APK cannot
+// make the server fetch internal addresses.
+var firebaseDatabaseHosts = []string{".firebaseio.com", ".firebasedatabase.app"}
+
+// firebaseResources maps the string resources written by the google-services
+// plugin to the field of the config they fill
+var firebaseResources = map[string]func(config *models.FirebaseConfig, value string){
+	"google_api_key":                 func(c *models.FirebaseConfig, v string) { c.GoogleAPIKey = v },
+	"google_app_id":                  func(c *models.FirebaseConfig, v string) { c.GoogleAppID = v },
+	"firebase_database_url":          func(c *models.FirebaseConfig, v string) { c.DatabaseURL = v },
+	"google_storage_bucket":          func(c *models.FirebaseConfig, v string) { c.StorageBucket = v },
+	"project_id":                     func(c *models.FirebaseConfig, v string) { c.ProjectID = v },
+	"gcm_defaultSenderId":            func(c *models.FirebaseConfig, v string) { c.GCMDefaultSenderID = v },
+	"google_crash_reporting_api_key": func(c *models.FirebaseConfig, v string) { c.CrashReportingAPIKey = v },
+	"default_web_client_id":          func(c *models.FirebaseConfig, v string) { c.DefaultWebClientID = v },
+}
+
+// ExtractFirebaseConfig reads the Firebase and Google services values from the
+// decoded string resources under resDir. The default values/ directory wins
+// over qualified ones such as values-de/.
+func ExtractFirebaseConfig(resDir string) models.FirebaseConfig {
+	var files []string
+	walkFiles(resDir, func(path string) {
+		if strings.HasSuffix(path, ".xml") && strings.HasPrefix(filepath.Base(filepath.Dir(path)), "values") {
+			files = append(files, path)
+		}
+	})
+	sort.SliceStable(files, func(i, j int) bool {
+		return filepath.Base(filepath.Dir(files[i])) == "values" && filepath.Base(filepath.Dir(files[j])) != "values"
+	})
+
+	var config models.FirebaseConfig
+	seen := make(map[string]bool)
+	for _, path := range files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			log.Debugf("Unable to read %s: %v", path, err)
+			continue
+		}
+		for _, match := range xmlStringRegex.FindAllStringSubmatch(string(content), -1) {
+			name, value := match[1], strings.TrimSpace(html.UnescapeString(match[2]))
+			setter, ok := firebaseResources[name]
+			if !ok || seen[name] || value == "" {
+				continue
+			}
+			seen[name] = true
+			setter(&config, value)
+		}
+	}
+	return config
+}
+
+// FirebaseChecker probes the endpoints named in a Firebase config for
+// unauthenticated access
+type FirebaseChecker struct {
+	client *http.Client
+	// DatabaseBaseURL replaces the Realtime Database host. The database name
+	// is then passed as the ns parameter, as the Firebase emulator expects.
+	DatabaseBaseURL string
+	StorageBaseURL  string
+}
+
+// NewFirebaseChecker creates a checker using firebase.database_base_url,
+// firebase.storage_base_url and firebase.timeout from viper
+func NewFirebaseChecker() *FirebaseChecker {
+	timeout := defaultFirebaseTimeout
+	if vip.IsSet("firebase.timeout") {
+		timeout = vip.GetDuration("firebase.timeout")
+	}
+
+	checker := &FirebaseChecker{
+		client:          &http.Client{Timeout: timeout},
+		DatabaseBaseURL: vip.GetString("firebase.database_base_url"),
+		StorageBaseURL:  vip.GetString("firebase.storage_base_url"),
+	}
+	if checker.StorageBaseURL == "" {
+		checker.StorageBaseURL = defaultFirebaseStorageURL
+	}
+	return checker
+}
+
+// Check runs every check the config has the values for
+func (c *FirebaseChecker) Check(config models.FirebaseConfig) []models.FirebaseCheck {
+	var checks []models.FirebaseCheck
+	if config.DatabaseURL != "" {
+		checks = append(checks, c.checkDatabaseRead(config.DatabaseURL))
+	}
+	if config.StorageBucket != "" {
+		checks = append(checks, c.checkBucketListing(config.StorageBucket))
+	}
+	return checks
+}
+
+// checkDatabaseRead tries to read the Realtime Database root without credentials
+func (c *FirebaseChecker) checkDatabaseRead(databaseURL string) models.FirebaseCheck {
+	check := models.FirebaseCheck{Name: "realtime_database_read", Target: databaseURL}
+
+	parsed, err := url.Parse(databaseURL)
+	if err != nil || parsed.Host == "" {
+		check.Status = models.FirebaseCheckError
+		check.Detail = "Invalid database URL"
+		return check
+	}
+
+	if c.DatabaseBaseURL != "" {
+		namespace := strings.SplitN(parsed.Hostname(), ".", 2)[0]
+		endpoint := strings.TrimRight(c.DatabaseBaseURL, "/") + "/.json?shallow=true&ns=" + url.QueryEscape(namespace)
+		return c.probe(check, endpoint, "Database contents are readable without authentication")
+	}
+
+	if !isFirebaseDatabaseURL(parsed) {
+		check.Status = models.FirebaseCheckError
+		check.Detail = "Not a Firebase Realtime Database URL"
+		return check
+	}
+	endpoint := "https://" + parsed.Host + "/.json?shallow=true"
+
+	return c.probe(check, endpoint, "Database contents are readable without authentication")
+}
+
+// isFirebaseDatabaseURL reports whether a URL is an https URL on one of the
+// Realtime Database domains
+func isFirebaseDatabaseURL(parsed *url.URL) bool {
+	if parsed.Scheme != "https" || parsed.User != nil || parsed.Port() != "" {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, domain := range firebaseDatabaseHosts {
+		if strings.HasSuffix(host, domain) && len(host) > len(domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkBucketListing tries to list the objects of the storage bucket without credentials
+func (c *FirebaseChecker) checkBucketListing(bucket string) models.FirebaseCheck {
+	bucket = strings.TrimSuffix(strings.TrimPrefix(bucket, "gs://"), "/")
+	check := models.FirebaseCheck{Name: "storage_bucket_listing", Target: bucket}
+
+	endpoint := strings.TrimRight(c.StorageBaseURL, "/") + "/v0/b/" + url.PathEscape(bucket) + "/o?maxResults=1"
+	return c.probe(check, endpoint, "Bucket objects can be listed without authentication")
+}
+
+// probe issues an unauthenticated GET and classifies the response
+func (c *FirebaseChecker) probe(check models.FirebaseCheck, endpoint string, exposedDetail string) models.FirebaseCheck {
+	resp, err := c.client.Get(endpoint)
+	if err != nil {
+		check.Status = models.FirebaseCheckError
+		check.Detail = err.Error()
+		return check
+	}
+	defer resp.Body.Close()
+
+	check.StatusCode = resp.StatusCode
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		check.Status = models.FirebaseCheckVulnerable
+		check.Detail = exposedDetail
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		check.Status = models.FirebaseCheckSecure
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusLocked:
+		check.Status = models.FirebaseCheckNotFound
+	default:
+		check.Status = models.FirebaseCheckError
+		check.Detail = fmt.Sprintf("Unexpected response %s", resp.Status)
+	}
+	return check
+}
+
+// StartFirebaseAnalysis extracts the Firebase config from the decoded resources
+// and, when firebase.checks is on, probes its endpoints
+func StartFirebaseAnalysis(resDir string) models.FirebaseConfig {
+	config := ExtractFirebaseConfig(resDir)
+	if config.IsEmpty() {
+		return config
+	}
+	log.Infof("Firebase config found for project %q", config.ProjectID)
+
+	if vip.GetBool("firebase.checks") {
+		config.Checks = NewFirebaseChecker().Check(config)
+		for _, check := range config.Checks {
+			log.Infof("Firebase check %s on %s: %s", check.Name, check.Target, check.Status)
+		}
+	}
+	return config
+}
@@ -0,0 +1,238 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// PatternImporter converts the rule file of another secret scanner into patterns
+type PatternImporter func(data []byte, options ImportOptions) (SecretPatterns, []ImportIssue, error)
+
+// patternImporters maps a --from format to its importer
+var patternImporters = map[string]PatternImporter{
+	"gitleaks": importGitleaksRules,
+}
+
+// ImportOptions holds the values imported rules have no equivalent for
+type ImportOptions struct {
+	Confidence string
+}
+
+// ImportIssue records a rule, or a part of one, that could not be translated.
+// Rule is empty for issues affecting the whole file.
+type ImportIssue struct {
+	Rule    string
+	Message string
+	Skipped bool // the rule was left out, otherwise it was imported without the part
+}
+
+func (i ImportIssue) String() string {
+	rule := i.Rule
+	if rule == "" {
+		rule = "(config)"
+	}
+	if i.Skipped {
+		return fmt.Sprintf("%s: skipped, %s", rule, i.Message)
+	}
+	return fmt.Sprintf("%s: %s", rule, i.Message)
+}
+
+// PatternImportFormats returns the formats ImportPatterns understands
+func PatternImportFormats() []string {
+	var formats []string
+	for format := range patternImporters {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// ImportPatterns converts a rule file in the given format into pattern
+// definitions, reporting whatever could not be carried over
+func ImportPatterns(format string, data []byte, options ImportOptions) (SecretPatterns, []ImportIssue, error) {
+	importer, ok := patternImporters[strings.ToLower(format)]
+	if !ok {
+		return SecretPatterns{}, nil, fmt.Errorf("unknown rule format %q, supported: %s",
+			format, strings.Join(PatternImportFormats(), ", "))
+	}
+	if options.Confidence == "" {
+		options.Confidence = "medium"
+	}
+	return importer(data, options)
+}
+
+type gitleaksConfig struct {
+	Title  string `toml:"title"`
+	Extend struct {
+		UseDefault bool   `toml:"useDefault"`
+		Path       string `toml:"path"`
+		URL        string `toml:"url"`
+	} `toml:"extend"`
+	Rules      []gitleaksRule      `toml:"rules"`
+	Allowlist  *gitleaksAllowlist  `toml:"allowlist"`
+	Allowlists []gitleaksAllowlist `toml:"allowlists"`
+}
+
+type gitleaksRule struct {
+	ID          string              `toml:"id"`
+	Description string              `toml:"description"`
+	Regex       string              `toml:"regex"`
+	SecretGroup int                 `toml:"secretGroup"`
+	Entropy     float64             `toml:"entropy"`
+	Keywords    []string            `toml:"keywords"`
+	Path        string              `toml:"path"`
+	Tags        []string            `toml:"tags"`
+	Allowlist   *gitleaksAllowlist  `toml:"allowlist"`
+	Allowlists  []gitleaksAllowlist `toml:"allowlists"`
+}
+
+type gitleaksAllowlist struct {
+	Description string   `toml:"description"`
+	Condition   string   `toml:"condition"`
+	RegexTarget string   `toml:"regexTarget"`
+	Regexes     []string `toml:"regexes"`
+	Paths       []string `toml:"paths"`
+	Commits     []string `toml:"commits"`
+	Stopwords   []string `toml:"stopwords"`
+}
+
+// allowlists returns the single [allowlist] table followed by the [[allowlists]] entries
+func gitleaksAllowlists(single *gitleaksAllowlist, list []gitleaksAllowlist) []gitleaksAllowlist {
+	if single == nil {
+		return list
+	}
+	return append([]gitleaksAllowlist{*single}, list...)
+}
+
+// importGitleaksRules converts a gitleaks TOML config. Regexes, keywords,
+// secretGroup, entropy and secret allowlists carry over; path filters are
+// regexes in gitleaks and globs here, so they are dropped with an issue.
+func importGitleaksRules(data []byte, options ImportOptions) (SecretPatterns, []ImportIssue, error) {
+	var config gitleaksConfig
+	if err := toml.Unmarshal(data, &config); err != nil {
+		return SecretPatterns{}, nil, fmt.Errorf("parsing gitleaks config: %v", err)
+	}
+
+	var issues []ImportIssue
+	if config.Extend.UseDefault || config.Extend.Path != "" || config.Extend.URL != "" {
+		issues = append(issues, ImportIssue{Message: "[extend] is not followed, import the extended config separately"})
+	}
+
+	// The global allowlist applies to every rule, so its secret parts are
+	// copied into each imported pattern
+	var global PatternAllowlist
+	for _, allowlist := range gitleaksAllowlists(config.Allowlist, config.Allowlists) {
+		var dropped []string
+		global, dropped = translateGitleaksAllowlist(global, allowlist)
+		for _, reason := range dropped {
+			issues = append(issues, ImportIssue{Message: "global allowlist " + reason})
+		}
+	}
+
+	var patterns SecretPatterns
+	seen := make(map[string]bool)
+	for index, rule := range config.Rules {
+		name := rule.ID
+		if name == "" {
+			name = fmt.Sprintf("rule %d", index+1)
+		}
+
+		switch {
+		case rule.ID == "":
+			issues = append(issues, ImportIssue{Rule: name, Message: "rule has no id", Skipped: true})
+			continue
+		case seen[rule.ID]:
+			issues = append(issues, ImportIssue{Rule: name, Message: "duplicate rule id", Skipped: true})
+			continue
+		case rule.Regex == "":
+			issues = append(issues, ImportIssue{Rule: name, Message: "path-only rules have no equivalent", Skipped: true})
+			continue
+		}
+		seen[rule.ID] = true
+
+		definition := PatternDefinition{
+			Name:        rule.ID,
+			Regex:       rule.Regex,
+			Confidence:  options.Confidence,
+			Keywords:    rule.Keywords,
+			Description: rule.Description,
+			SecretGroup: rule.SecretGroup,
+			Entropy:     rule.Entropy,
+			Allowlist: PatternAllowlist{
+				Regexes:   append([]string(nil), global.Regexes...),
+				Stopwords: append([]string(nil), global.Stopwords...),
+			},
+		}
+		if rule.Path != "" {
+			issues = append(issues, ImportIssue{Rule: name, Message: fmt.Sprintf("path regex %q dropped, the pattern now applies to every file", rule.Path)})
+		}
+		for _, allowlist := range gitleaksAllowlists(rule.Allowlist, rule.Allowlists) {
+			var dropped []string
+			definition.Allowlist, dropped = translateGitleaksAllowlist(definition.Allowlist, allowlist)
+			for _, reason := range dropped {
+				issues = append(issues, ImportIssue{Rule: name, Message: "allowlist " + reason})
+			}
+		}
+
+		// Go's RE2 lacks some constructs gitleaks configs may use, such as lookarounds
+		if _, err := compilePattern(definition); err != nil {
+			issues = append(issues, ImportIssue{Rule: name, Message: err.Error(), Skipped: true})
+			continue
+		}
+		patterns.Patterns = append(patterns.Patterns, PatternEntry{Pattern: definition})
+	}
+
+	return patterns, issues, nil
+}
+
+// translateGitleaksAllowlist adds the parts of a gitleaks allowlist that test
+// the secret itself and returns a reason for each part that was dropped
+func translateGitleaksAllowlist(into PatternAllowlist, allowlist gitleaksAllowlist) (PatternAllowlist, []string) {
+	var dropped []string
+
+	criteria := 0
+	for _, part := range [][]string{allowlist.Regexes, allowlist.Paths, allowlist.Commits, allowlist.Stopwords} {
+		if len(part) > 0 {
+			criteria++
+		}
+	}
+	if strings.EqualFold(allowlist.Condition, "AND") && criteria > 1 {
+		// Entries here allow on any match, so only a single AND criterion keeps its meaning
+		return into, []string{"with condition AND across several criteria dropped"}
+	}
+
+	if len(allowlist.Regexes) > 0 {
+		switch strings.ToLower(allowlist.RegexTarget) {
+		case "", "secret":
+			into.Regexes = append(into.Regexes, allowlist.Regexes...)
+		default:
+			dropped = append(dropped, fmt.Sprintf("regexes with regexTarget %q dropped, only the secret is tested", allowlist.RegexTarget))
+		}
+	}
+	into.Stopwords = append(into.Stopwords, allowlist.Stopwords...)
+	if len(allowlist.Paths) > 0 {
+		dropped = append(dropped, fmt.Sprintf("paths %s dropped", strings.Join(allowlist.Paths, ", ")))
+	}
+	// Commits only make sense for git history, which is never scanned here
+
+	return into, dropped
+}
@@ -0,0 +1,138 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+const gitleaksSample = `
+title = "sample"
+
+[extend]
+useDefault = true
+
+[allowlist]
+stopwords = ["example"]
+paths = ['''vendor/''']
+
+[[rules]]
+id = "generic-api-key"
+description = "Generic API Key"
+regex = '''(?i)api_?key\s*=\s*"([0-9a-z]{24})"'''
+secretGroup = 1
+entropy = 3.5
+keywords = ["api_key", "apikey"]
+
+  [[rules.allowlists]]
+  regexes = ['''^0+$''']
+
+  [[rules.allowlists]]
+  regexTarget = "line"
+  regexes = ['''test''']
+
+[[rules]]
+id = "pkcs12-file"
+path = '''\.p12$'''
+
+[[rules]]
+id = "lookahead"
+regex = '''secret(?=_)'''
+
+[[rules]]
+id = "scoped"
+regex = '''tok_[0-9a-f]{16}'''
+path = '''\.env$'''
+
+  [rules.allowlist]
+  condition = "AND"
+  regexes = ['''dead''']
+  paths = ['''test''']
+`
+
+func TestImportGitleaksRules(t *testing.T) {
+	patterns, issues, err := ImportPatterns("gitleaks", []byte(gitleaksSample), ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(patterns.Patterns) != 2 {
+		t.Fatalf("got %d patterns, want 2", len(patterns.Patterns))
+	}
+	generic := patterns.Patterns[0].Pattern
+	if generic.Name != "generic-api-key" || generic.SecretGroup != 1 || generic.Entropy != 3.5 ||
+		generic.Confidence != "medium" || len(generic.Keywords) != 2 {
+		t.Errorf("unexpected translation: %+v", generic)
+	}
+	if strings.Join(generic.Allowlist.Stopwords, ",") != "example" || strings.Join(generic.Allowlist.Regexes, ",") != "^0+$" {
+		t.Errorf("unexpected allowlist: %+v", generic.Allowlist)
+	}
+	if scoped := patterns.Patterns[1].Pattern; len(scoped.Allowlist.Regexes) != 0 {
+		t.Errorf("AND allowlist should be dropped, got %+v", scoped.Allowlist)
+	}
+
+	want := map[string]bool{
+		"(config): [extend]":                      false,
+		"(config): global allowlist paths":        false,
+		"generic-api-key: allowlist regexes with": false,
+		"pkcs12-file: skipped":                    false,
+		"lookahead: skipped":                      false,
+		"scoped: path regex":                      false,
+		"scoped: allowlist with condition AND":    false,
+	}
+	for _, issue := range issues {
+		for prefix := range want {
+			if strings.HasPrefix(issue.String(), prefix) {
+				want[prefix] = true
+			}
+		}
+	}
+	for prefix, found := range want {
+		if !found {
+			t.Errorf("missing issue %q in %v", prefix, issues)
+		}
+	}
+
+	// The output must load as a pattern file and match like the original rule
+	data, err := yaml.Marshal(patterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded SecretPatterns
+	if err := yaml.UnmarshalStrict(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	compiled, err := compilePattern(loaded.Patterns[0].Pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !compiled.matchesSample(`api_key = "a8f3k2m9q7x1z5c4v6b0n2l8"`) {
+		t.Error("high entropy key not matched")
+	}
+	if compiled.matchesSample(`api_key = "aaaaaaaaaaaaaaaaaaaaaaab"`) {
+		t.Error("low entropy key matched")
+	}
+}
+
+func TestImportUnknownFormat(t *testing.T) {
+	if _, _, err := ImportPatterns("trufflehog", nil, ImportOptions{}); err == nil || !strings.Contains(err.Error(), "gitleaks") {
+		t.Errorf("expected an error listing the supported formats, got %v", err)
+	}
+}
@@ -0,0 +1,376 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"html"
+	"morf/models"
+	"morf/utils"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	vip "github.com/spf13/viper"
+)
+
+// jwtPatternName is the type of the findings reported by the built-in JWT detector
+const jwtPatternName = "JSON Web Token"
+
+// defaultJWTMaxLifetime is the longest validity not flagged as long_expiry
+const defaultJWTMaxLifetime = 30 * 24 * time.Hour
+
+// maxJWTSecretLength bounds the literals tried as HMAC signing secrets
+const maxJWTSecretLength = 1024
+
+var (
+	// Header and claims are base64url JSON objects, so both start with eyJ.
+	// The signature is empty for alg none.
+	jwtRegex = regexp.MustCompile(`eyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]*`)
+
+	privilegedValueRegex = regexp.MustCompile(`(?i)(?:^|[^a-z])(?:admin|administrator|root|superuser|super_admin|superadmin|owner|sudo)(?:[^a-z]|$)|^\*$|:\*$|(?:^|[:._])(?:all|full_access)$`)
+	privilegedFlagRegex  = regexp.MustCompile(`(?i)^(?:is_?)?(?:admin|superuser|super_admin|root|staff)$`)
+)
+
+// privilegeClaims hold scopes, roles or groups
+var privilegeClaims = map[string]bool{
+	"scope": true, "scopes": true, "scp": true, "role": true, "roles": true, "groups": true,
+	"permissions": true, "authorities": true, "realm_access": true, "resource_access": true,
+}
+
+// publicClaims describe the token rather than its subject and are not masked
+var publicClaims = map[string]bool{
+	"iss": true, "aud": true, "exp": true, "nbf": true, "iat": true, "typ": true, "token_type": true,
+	"azp": true, "amr": true, "acr": true,
+}
+
+var jwtHMACs = map[string]func() hash.Hash{"HS256": sha256.New, "HS384": sha512.New384, "HS512": sha512.New}
+
+var severityOrder = map[string]int{"info": 1, "low": 2, "medium": 3, "high": 4, "critical": 5}
+
+// matchJWTs reports the JWTs in content that no pattern has already reported
+func (m *SecretMatcher) matchJWTs(path string, content []byte, lines lineIndex, known []models.SecretModel) []models.SecretModel {
+	if !bytes.Contains(content, []byte("eyJ")) {
+		return nil
+	}
+
+	reported := make(map[string]bool, len(known))
+	for _, finding := range known {
+		reported[finding.SecretString] = true
+	}
+
+	var findings []models.SecretModel
+	for _, loc := range jwtRegex.FindAllIndex(content, -1) {
+		token := string(content[loc[0]:loc[1]])
+		if reported[token] {
+			continue
+		}
+		if _, err := DecodeJWT(token); err != nil {
+			continue
+		}
+		reported[token] = true
+
+		lineNo, column := lines.position(loc[0])
+		endLineNo, endColumn := lines.position(loc[1])
+		log.Infof("Matches: %s:%d:%s", path, lineNo, utils.MaskSecret(token, utils.RedactionMode(utils.SinkLog)))
+
+		findings = append(findings, models.SecretModel{
+			Type:             jwtPatternName,
+			LineNo:           lineNo,
+			ColumnNo:         column,
+			EndLineNo:        endLineNo,
+			EndColumnNo:      endColumn,
+			FileLocation:     path,
+			SecretType:       jwtPatternName,
+			SecretString:     token,
+			SecretConfidence: "high",
+			Severity:         "medium",
+			CWE:              "CWE-798",
+			Description:      "Hardcoded JSON Web Token",
+			Remediation:      "Issue tokens from a backend at runtime instead of shipping them in the APK",
+		})
+	}
+	return findings
+}
+
+// DecodeJWT decodes the header and claims of a token. Claims that are neither
+// registered nor privilege related are partially masked.
+func DecodeJWT(token string) (*models.JWTDetails, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("a JWT has three parts")
+	}
+
+	header, err := decodeJWTPart(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid header: %v", err)
+	}
+	if _, ok := header["alg"]; !ok {
+		return nil, errors.New("header has no alg")
+	}
+	claims, err := decodeJWTPart(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid claims: %v", err)
+	}
+
+	return &models.JWTDetails{Header: header, Claims: claims}, nil
+}
+
+func decodeJWTPart(part string) (map[string]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(part, "="))
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var values map[string]interface{}
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// AnalyzeJWTs decodes every finding holding a JWT and flags its weaknesses.
+// literals lists the strings of the app, which are tried as HMAC signing
+// secrets; it is only called when an HMAC signed token was found.
+func AnalyzeJWTs(findings []models.SecretModel, literals func() []string) {
+	var candidates []string
+	loaded := false
+
+	for i := range findings {
+		token := findings[i].SecretString
+		if jwtRegex.FindString(token) != token {
+			continue
+		}
+		details, err := DecodeJWT(token)
+		if err != nil {
+			continue
+		}
+
+		alg, _ := details.Header["alg"].(string)
+		if strings.EqualFold(alg, "none") {
+			details.Weaknesses = append(details.Weaknesses, models.JWTWeaknessAlgNone)
+		}
+		if newHash, ok := jwtHMACs[strings.ToUpper(alg)]; ok {
+			if !loaded {
+				candidates = uniqueStrings(append(secretValues(findings), literals()...))
+				loaded = true
+			}
+			if jwtSecretIn(token, newHash, candidates) {
+				details.Weaknesses = append(details.Weaknesses, models.JWTWeaknessSecretInApp)
+			}
+		}
+		details.Weaknesses = append(details.Weaknesses, expiryWeaknesses(details.Claims, time.Now())...)
+		if hasPrivilegedClaims(details.Claims) {
+			details.Weaknesses = append(details.Weaknesses, models.JWTWeaknessPrivilegedRole)
+		}
+
+		details.Claims = maskClaims(details.Claims)
+		findings[i].JWT = details
+		findings[i].Severity = jwtSeverity(findings[i].Severity, details.Weaknesses)
+	}
+}
+
+// secretValues returns the secret strings of the findings
+func secretValues(findings []models.SecretModel) []string {
+	values := make([]string, 0, len(findings))
+	for _, finding := range findings {
+		values = append(values, finding.SecretString)
+	}
+	return values
+}
+
+// jwtSecretIn reports whether any candidate verifies the token's HMAC signature
+func jwtSecretIn(token string, newHash func() hash.Hash, candidates []string) bool {
+	dot := strings.LastIndexByte(token, '.')
+	signature, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(token[dot+1:], "="))
+	if err != nil || len(signature) == 0 {
+		return false
+	}
+
+	signingInput := []byte(token[:dot])
+	for _, candidate := range candidates {
+		if candidate == "" || len(candidate) > maxJWTSecretLength || candidate == token {
+			continue
+		}
+		mac := hmac.New(newHash, []byte(candidate))
+		mac.Write(signingInput)
+		if hmac.Equal(mac.Sum(nil), signature) {
+			return true
+		}
+	}
+	return false
+}
+
+// expiryWeaknesses flags tokens without exp or valid for longer than
+// jwt.max_lifetime, measured from iat or else from now
+func expiryWeaknesses(claims map[string]interface{}, now time.Time) []string {
+	exp, ok := numericClaim(claims, "exp")
+	if !ok {
+		return []string{models.JWTWeaknessNoExpiry}
+	}
+
+	maxLifetime := defaultJWTMaxLifetime
+	if vip.IsSet("jwt.max_lifetime") {
+		maxLifetime = vip.GetDuration("jwt.max_lifetime")
+	}
+
+	issued := float64(now.Unix())
+	if iat, ok := numericClaim(claims, "iat"); ok {
+		issued = iat
+	}
+	if exp-issued > maxLifetime.Seconds() {
+		return []string{models.JWTWeaknessLongExpiry}
+	}
+	return nil
+}
+
+func numericClaim(claims map[string]interface{}, name string) (float64, bool) {
+	number, ok := claims[name].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	value, err := number.Float64()
+	return value, err == nil
+}
+
+// hasPrivilegedClaims looks for admin-like roles and wildcard scopes, and for
+// boolean flags such as is_admin
+func hasPrivilegedClaims(claims map[string]interface{}) bool {
+	for name, value := range claims {
+		if flag, ok := value.(bool); ok && flag && privilegedFlagRegex.MatchString(name) {
+			return true
+		}
+		if privilegeClaims[strings.ToLower(name)] && hasPrivilegedValue(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPrivilegedValue(value interface{}) bool {
+	switch value := value.(type) {
+	case string:
+		for _, token := range strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == ',' }) {
+			if privilegedValueRegex.MatchString(token) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			if hasPrivilegedValue(item) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range value {
+			if hasPrivilegedValue(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// maskClaims partially masks the claims that may identify a user, such as sub or email
+func maskClaims(claims map[string]interface{}) map[string]interface{} {
+	masked := make(map[string]interface{}, len(claims))
+	for name, value := range claims {
+		if publicClaims[name] || privilegeClaims[strings.ToLower(name)] {
+			masked[name] = value
+			continue
+		}
+		if _, ok := value.(bool); ok {
+			masked[name] = value
+			continue
+		}
+
+		text, ok := value.(string)
+		if !ok {
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				continue
+			}
+			text = string(encoded)
+		}
+		masked[name] = utils.MaskSecret(text, utils.RedactPartial)
+	}
+	return masked
+}
+
+// jwtSeverity raises a finding's severity to match the weaknesses of its token
+func jwtSeverity(severity string, weaknesses []string) string {
+	raised := severity
+	for _, weakness := range weaknesses {
+		target := "high"
+		if weakness == models.JWTWeaknessAlgNone || weakness == models.JWTWeaknessSecretInApp {
+			target = "critical"
+		}
+		if severityOrder[target] > severityOrder[raised] {
+			raised = target
+		}
+	}
+	return raised
+}
+
+// appStringLiterals returns the const-string literals of the smali sources
+// under sourceDir and the string resources under resDir
+func appStringLiterals(sourceDir, resDir string) []string {
+	var literals []string
+	walkFiles(sourceDir, func(path string) {
+		if !strings.HasSuffix(path, ".smali") {
+			return
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return
+		}
+		for _, match := range constStringRegex.FindAllStringSubmatch(string(content), -1) {
+			literal, err := strconv.Unquote(`"` + match[1] + `"`)
+			if err != nil {
+				literal = match[1]
+			}
+			literals = append(literals, literal)
+		}
+	})
+	walkFiles(resDir, func(path string) {
+		if !strings.HasSuffix(path, ".xml") || !strings.HasPrefix(filepath.Base(filepath.Dir(path)), "values") {
+			return
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return
+		}
+		for _, match := range xmlStringRegex.FindAllStringSubmatch(string(content), -1) {
+			literals = append(literals, html.UnescapeString(match[2]))
+		}
+	})
+	return literals
+}
@@ -0,0 +1,97 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"morf/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// buildTestJWT encodes a token, signing it with HS256 when secret is set
+func buildTestJWT(header, claims, secret string) string {
+	input := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+	if secret == "" {
+		return input + "."
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestMatchJWTs(t *testing.T) {
+	token := buildTestJWT(`{"alg":"none"}`, `{"sub":"1234567890","exp":1700000000,"iat":1699990000}`, "")
+	content := []byte("    const-string v0, \"" + token + "\"\n")
+
+	findings := NewSecretMatcher().ScanContent("Auth.smali", content)
+	if len(findings) != 1 {
+		t.Fatalf("got %d findings, want 1: %+v", len(findings), findings)
+	}
+	if findings[0].SecretString != token || findings[0].Type != jwtPatternName || findings[0].ColumnNo != 23 {
+		t.Errorf("finding = %+v", findings[0])
+	}
+
+	if findings := NewSecretMatcher().ScanContent("Auth.smali", []byte(`"eyJub3QiOiJqc29uIn0.eyJub3QiOiJqc29uIn0x.abc"`)); len(findings) != 0 {
+		t.Errorf("undecodable token reported: %+v", findings)
+	}
+}
+
+func TestAnalyzeJWTs(t *testing.T) {
+	signed := buildTestJWT(`{"alg":"HS256","typ":"JWT"}`, `{"sub":"user-42-abcdef","email":"dev@example.com","roles":["user","admin"]}`, "s3cr3t-signing-key")
+	none := buildTestJWT(`{"alg":"none"}`, `{"iat":1700000000,"exp":1700003600,"scope":"profile"}`, "")
+	long := buildTestJWT(`{"alg":"RS256"}`, `{"iat":1700000000,"exp":1900000000,"is_admin":true}`, "")
+
+	findings := []models.SecretModel{
+		{SecretString: signed, Severity: "medium"},
+		{SecretString: none, Severity: "medium"},
+		{SecretString: long, Severity: "low"},
+		{SecretString: "not-a-token"},
+	}
+	AnalyzeJWTs(findings, func() []string { return []string{"other", "s3cr3t-signing-key"} })
+
+	want := [][]string{
+		{models.JWTWeaknessSecretInApp, models.JWTWeaknessNoExpiry, models.JWTWeaknessPrivilegedRole},
+		{models.JWTWeaknessAlgNone},
+		{models.JWTWeaknessLongExpiry, models.JWTWeaknessPrivilegedRole},
+	}
+	for i, weaknesses := range want {
+		if findings[i].JWT == nil || !reflect.DeepEqual(findings[i].JWT.Weaknesses, weaknesses) {
+			t.Errorf("finding %d JWT = %+v, want weaknesses %v", i, findings[i].JWT, weaknesses)
+		}
+	}
+	if findings[0].Severity != "critical" || findings[2].Severity != "high" {
+		t.Errorf("severities = %q, %q", findings[0].Severity, findings[2].Severity)
+	}
+	if findings[3].JWT != nil {
+		t.Errorf("non-JWT finding decoded: %+v", findings[3].JWT)
+	}
+
+	claims := findings[0].JWT.Claims
+	if email, _ := claims["email"].(string); email == "dev@example.com" || !strings.Contains(email, "*") {
+		t.Errorf("email claim not masked: %v", claims["email"])
+	}
+	if !reflect.DeepEqual(claims["roles"], []interface{}{"user", "admin"}) {
+		t.Errorf("roles claim = %v", claims["roles"])
+	}
+	if findings[0].JWT.Header["alg"] != "HS256" {
+		t.Errorf("header = %v", findings[0].JWT.Header)
+	}
+}
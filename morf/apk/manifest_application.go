@@ -0,0 +1,136 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"fmt"
+	"morf/models"
+)
+
+// Platform versions whose defaults change the risk of application attributes
+// and the exported state of components
+const (
+	sdkJellyBeanMR1 = 17 // providers are no longer exported by default from here on
+	sdkPie          = 28 // cleartext traffic is blocked by default from here on
+	sdkS            = 31 // adb backup is off by default, and intent filters need android:exported, from here on
+)
+
+// extractApplicationInfo reads the security relevant attributes of
+// <application>, and sharedUserId from <manifest>, and flags risky values
+func extractApplicationInfo(manifest *XMLElement, targetSdk int) models.ManifestApplicationInfo {
+	application := manifest.Child("application")
+	optionalBool := func(name string) *bool {
+		if value, ok := application.AndroidAttr(name).Bool(); ok {
+			return &value
+		}
+		return nil
+	}
+
+	info := models.ManifestApplicationInfo{
+		Debuggable:                   optionalBool("debuggable"),
+		AllowBackup:                  optionalBool("allowBackup"),
+		FullBackupContent:            application.AndroidString("fullBackupContent"),
+		DataExtractionRules:          application.AndroidString("dataExtractionRules"),
+		UsesCleartextTraffic:         optionalBool("usesCleartextTraffic"),
+		NetworkSecurityConfig:        application.AndroidString("networkSecurityConfig"),
+		TestOnly:                     optionalBool("testOnly"),
+		SharedUserID:                 manifest.AndroidString("sharedUserId"),
+		ExtractNativeLibs:            optionalBool("extractNativeLibs"),
+		RequestLegacyExternalStorage: optionalBool("requestLegacyExternalStorage"),
+	}
+	info.Findings = applicationFindings(info, targetSdk)
+	return info
+}
+
+// applicationFindings flags the application attributes, explicit or
+// defaulted from the target SDK, that weaken the app's security
+func applicationFindings(info models.ManifestApplicationInfo, targetSdk int) []models.ManifestFinding {
+	var findings []models.ManifestFinding
+	add := func(id string, attribute string, value string, severity string, description string) {
+		findings = append(findings, models.ManifestFinding{
+			ID:          id,
+			Attribute:   attribute,
+			Value:       value,
+			Severity:    severity,
+			Description: description,
+		})
+	}
+	isTrue := func(value *bool) bool { return value != nil && *value }
+
+	if isTrue(info.Debuggable) {
+		add("debuggable", "android:debuggable", "true", models.ManifestSeverityHigh,
+			"The app is debuggable: anyone with adb access can attach a debugger and run code as the app")
+	}
+
+	// Backups are on unless disabled; rules files limit what they contain
+	if info.AllowBackup == nil || *info.AllowBackup {
+		value := "true"
+		if info.AllowBackup == nil {
+			value = "default (true)"
+		}
+		switch {
+		case (info.FullBackupContent != "" && info.FullBackupContent != "true") || info.DataExtractionRules != "":
+			add("backup_enabled", "android:allowBackup", value, models.ManifestSeverityLow,
+				"App data can be backed up; backup rules restrict which files are included")
+		case info.AllowBackup == nil && targetSdk >= sdkS:
+			add("backup_enabled", "android:allowBackup", value, models.ManifestSeverityLow,
+				"App data is included in cloud backups and device transfers; adb backup is off for targetSdk 31+")
+		default:
+			add("backup_enabled", "android:allowBackup", value, models.ManifestSeverityMedium,
+				"All app data, including tokens and databases, can be extracted with adb backup")
+		}
+	}
+
+	// A network security config replaces the manifest flag from API 24 onwards
+	switch {
+	case isTrue(info.UsesCleartextTraffic) && info.NetworkSecurityConfig != "":
+		add("cleartext_traffic", "android:usesCleartextTraffic", "true", models.ManifestSeverityLow,
+			"The app allows unencrypted HTTP traffic on Android 6 and older; from API 24 its network security config takes precedence")
+	case isTrue(info.UsesCleartextTraffic):
+		add("cleartext_traffic", "android:usesCleartextTraffic", "true", models.ManifestSeverityMedium,
+			"The app allows unencrypted HTTP traffic")
+	case info.UsesCleartextTraffic == nil && info.NetworkSecurityConfig == "" && targetSdk < sdkPie:
+		add("cleartext_traffic", "android:usesCleartextTraffic", "default (true)", models.ManifestSeverityMedium,
+			fmt.Sprintf("Unencrypted HTTP traffic is allowed by default for targetSdk %d (below %d)", targetSdk, sdkPie))
+	}
+
+	if isTrue(info.TestOnly) {
+		add("test_only", "android:testOnly", "true", models.ManifestSeverityMedium,
+			"The APK is a test build, which is usually not meant to be distributed")
+	}
+
+	if info.SharedUserID != "" {
+		severity := models.ManifestSeverityLow
+		if info.SharedUserID == "android.uid.system" {
+			severity = models.ManifestSeverityHigh
+		}
+		add("shared_user_id", "android:sharedUserId", info.SharedUserID, severity,
+			"The app shares a Linux user ID, and with it data and permissions, with other apps signed by the same key")
+	}
+
+	if isTrue(info.ExtractNativeLibs) {
+		add("extract_native_libs", "android:extractNativeLibs", "true", models.ManifestSeverityInfo,
+			"Native libraries are extracted to the file system at install time instead of loaded from the APK")
+	}
+
+	if isTrue(info.RequestLegacyExternalStorage) {
+		add("legacy_external_storage", "android:requestLegacyExternalStorage", "true", models.ManifestSeverityLow,
+			"The app opts out of scoped storage and keeps broad access to shared external storage on Android 10")
+	}
+
+	return findings
+}
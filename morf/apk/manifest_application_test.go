@@ -0,0 +1,112 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"morf/models"
+	"testing"
+)
+
+func findingSeverities(findings []models.ManifestFinding) map[string]string {
+	severities := make(map[string]string)
+	for _, finding := range findings {
+		severities[finding.ID] = finding.Severity
+	}
+	return severities
+}
+
+func TestExtractApplicationInfo(t *testing.T) {
+	manifest := &XMLElement{Name: "manifest", Attributes: []XMLAttribute{
+		testAttr("sharedUserId", 0x0101000b, AttrTypeString, 0, "android.uid.system"),
+	}, Children: []*XMLElement{
+		{Name: "application", Attributes: []XMLAttribute{
+			testAttr("debuggable", 0x0101000f, AttrTypeIntBoolean, 0xffffffff, "true"),
+			testAttr("extractNativeLibs", 0x010104ea, AttrTypeIntBoolean, 0, "false"),
+			{Namespace: AndroidNamespace, Name: "requestLegacyExternalStorage", Type: AttrTypeIntBoolean, Data: 0xffffffff, Value: "true"},
+		}},
+	}}
+	manifest, err := ParseAXML(buildTestAXML(manifest, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := extractApplicationInfo(manifest, 26)
+	if info.Debuggable == nil || !*info.Debuggable || info.AllowBackup != nil || info.ExtractNativeLibs == nil || *info.ExtractNativeLibs {
+		t.Errorf("unexpected attributes: %+v", info)
+	}
+
+	want := map[string]string{
+		"debuggable":              models.ManifestSeverityHigh,
+		"backup_enabled":          models.ManifestSeverityMedium,
+		"cleartext_traffic":       models.ManifestSeverityMedium,
+		"shared_user_id":          models.ManifestSeverityHigh,
+		"legacy_external_storage": models.ManifestSeverityLow,
+	}
+	got := findingSeverities(info.Findings)
+	if len(got) != len(want) {
+		t.Errorf("got findings %v, want %v", got, want)
+	}
+	for id, severity := range want {
+		if got[id] != severity {
+			t.Errorf("%s: got severity %q, want %q", id, got[id], severity)
+		}
+	}
+}
+
+func TestApplicationFindingsDefaults(t *testing.T) {
+	disabled := false
+
+	// Modern defaults: backups reach the cloud only, cleartext is blocked
+	if got := findingSeverities(applicationFindings(models.ManifestApplicationInfo{}, 34)); len(got) != 1 || got["backup_enabled"] != models.ManifestSeverityLow {
+		t.Errorf("targetSdk 34 defaults: %v", got)
+	}
+	// A network security config takes precedence over the explicit flag too
+	enabled := true
+	if got := findingSeverities(applicationFindings(models.ManifestApplicationInfo{AllowBackup: &disabled, UsesCleartextTraffic: &enabled, NetworkSecurityConfig: "@0x7f180009"}, 34)); got["cleartext_traffic"] != models.ManifestSeverityLow {
+		t.Errorf("cleartext with a network security config: %v", got)
+	}
+	// A network security config takes over from the cleartext default
+	if got := findingSeverities(applicationFindings(models.ManifestApplicationInfo{AllowBackup: &disabled, NetworkSecurityConfig: "@0x7f180009"}, 23)); len(got) != 0 {
+		t.Errorf("hardened app: %v", got)
+	}
+}
+
+func TestExtractApplicationInfoByResourceID(t *testing.T) {
+	// Obfuscated manifests may keep only the resource IDs of attribute names
+	manifest := &XMLElement{Name: "manifest", Children: []*XMLElement{
+		{Name: "application", Attributes: []XMLAttribute{
+			testAttr("", 0x01010603, AttrTypeIntBoolean, 0xffffffff, "true"),
+			testAttr("", 0x0101063e, AttrTypeReference, 0x7f160000, "@0x7f160000"),
+			testAttr("", 0x010104ec, AttrTypeIntBoolean, 0, "false"),
+		}},
+	}}
+	manifest, err := ParseAXML(buildTestAXML(manifest, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := extractApplicationInfo(manifest, 30)
+	if info.RequestLegacyExternalStorage == nil || !*info.RequestLegacyExternalStorage {
+		t.Errorf("requestLegacyExternalStorage not read by resource ID: %+v", info)
+	}
+	if info.DataExtractionRules != "@0x7f160000" {
+		t.Errorf("dataExtractionRules = %q, want @0x7f160000", info.DataExtractionRules)
+	}
+	if info.UsesCleartextTraffic == nil || *info.UsesCleartextTraffic {
+		t.Errorf("usesCleartextTraffic not read by resource ID: %+v", info)
+	}
+}
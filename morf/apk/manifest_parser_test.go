@@ -19,22 +19,106 @@ package apk
 import (
 	"morf/models"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 )
 
+var (
+	dumpElementRegex   = regexp.MustCompile(`^E: (\S+) \(line=(\d+)\)`)
+	dumpAttributeRegex = regexp.MustCompile(`^A: (?:(\w+):)?([\w.-]+)(?:\((0x[0-9a-f]+)\))?=(.*)$`)
+	dumpTypedRegex     = regexp.MustCompile(`^\(type (0x[0-9a-f]+)\)(0x[0-9a-f]+)`)
+)
+
+// parseXMLTreeDump rebuilds the element tree printed by aapt dump xmltree,
+// so that the sample can be encoded as binary XML
+func parseXMLTreeDump(t *testing.T, dump string) *XMLElement {
+	var root *XMLElement
+	type open struct {
+		element *XMLElement
+		indent  int
+	}
+	var stack []open
+
+	for _, line := range strings.Split(dump, "\n") {
+		trimmed := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+
+		if match := dumpElementRegex.FindStringSubmatch(trimmed); match != nil {
+			lineNo, _ := strconv.Atoi(match[2])
+			element := &XMLElement{Name: match[1], Line: lineNo}
+			for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+				stack = stack[:len(stack)-1]
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1].element
+				parent.Children = append(parent.Children, element)
+			} else if root == nil {
+				root = element
+			}
+			stack = append(stack, open{element, indent})
+			continue
+		}
+
+		match := dumpAttributeRegex.FindStringSubmatch(trimmed)
+		if match == nil || len(stack) == 0 {
+			continue
+		}
+		attr := XMLAttribute{Name: match[2]}
+		if match[1] == "android" {
+			attr.Namespace = AndroidNamespace
+		}
+		if match[3] != "" {
+			id, _ := strconv.ParseUint(match[3], 0, 32)
+			attr.ResourceID = uint32(id)
+		}
+
+		value := match[4]
+		switch {
+		case strings.HasPrefix(value, `"`):
+			attr.Type = AttrTypeString
+			if end := strings.LastIndex(value, `" (Raw: `); end > 0 {
+				attr.Value = value[1:end]
+			} else {
+				attr.Value = strings.Trim(value, `"`)
+			}
+		case strings.HasPrefix(value, "@"):
+			data, _ := strconv.ParseUint(value[1:], 0, 32)
+			attr.Type, attr.Data = AttrTypeReference, uint32(data)
+		default:
+			typed := dumpTypedRegex.FindStringSubmatch(value)
+			if typed == nil {
+				t.Fatalf("unexpected attribute value in %q", trimmed)
+			}
+			attrType, _ := strconv.ParseUint(typed[1], 0, 8)
+			data, _ := strconv.ParseUint(typed[2], 0, 32)
+			attr.Type, attr.Data = uint8(attrType), uint32(data)
+		}
+		element := stack[len(stack)-1].element
+		element.Attributes = append(element.Attributes, attr)
+	}
+	return root
+}
+
 func TestParseManifestFromSample(t *testing.T) {
-	// Read the sample XML tree
+	// Read the sample XML tree and turn it back into a binary manifest
 	xmlTree, err := os.ReadFile("sample_xmltree.txt")
 	if err != nil {
 		t.Fatalf("Failed to read sample XML tree: %v", err)
 	}
+	manifest, err := ParseAXML(buildTestAXML(parseXMLTreeDump(t, string(xmlTree)), false))
+	if err != nil {
+		t.Fatalf("Failed to parse the encoded manifest: %v", err)
+	}
+	application := manifest.Child("application")
 
 	// Create a metadata model
 	metadata := &models.MetaDataModel{}
 	metadata.AndroidManifest.UsesTargetSdkVersion = "34" // Android 14
 
 	// Extract activities
-	activities := extractActivities(string(xmlTree), 34)
+	activities := extractActivities(application, 34)
 	t.Logf("Found %d activities", len(activities))
 
 	// Expected activity export states
@@ -60,7 +144,7 @@ func TestParseManifestFromSample(t *testing.T) {
 	}
 
 	// Extract services
-	services := extractServices(string(xmlTree), 34)
+	services := extractServices(application, 34)
 	t.Logf("Found %d services", len(services))
 
 	// Expected service export states
@@ -83,7 +167,7 @@ func TestParseManifestFromSample(t *testing.T) {
 	}
 
 	// Extract receivers
-	receivers := extractReceivers(string(xmlTree), 34)
+	receivers := extractReceivers(application, 34)
 	t.Logf("Found %d receivers", len(receivers))
 
 	// Expected receiver export states
@@ -107,7 +191,7 @@ func TestParseManifestFromSample(t *testing.T) {
 	}
 
 	// Extract providers
-	providers := extractProviders(string(xmlTree), 34)
+	providers := extractProviders(application, 34)
 	t.Logf("Found %d providers", len(providers))
 
 	// Expected provider export states
@@ -145,25 +229,3 @@ func TestParseManifestFromSample(t *testing.T) {
 	}
 	t.Logf("Total deeplinks found: %d", deeplinkCount)
 }
-
-func TestIsHexValueTrue(t *testing.T) {
-	tests := []struct {
-		name     string
-		hexValue string
-		want     bool
-	}{
-		{"True value", "0xffffffff", true},
-		{"False value", "0x0", false},
-		{"Invalid hex", "0x1", false},
-		{"Empty string", "", false},
-		{"Non-hex string", "true", false},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := isHexValueTrue(tt.hexValue); got != tt.want {
-				t.Errorf("isHexValueTrue(%q) = %v, want %v", tt.hexValue, got, tt.want)
-			}
-		})
-	}
-}
@@ -0,0 +1,225 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"fmt"
+	"morf/models"
+	"strconv"
+	"strings"
+)
+
+// Base protection levels of permissions
+const (
+	protectionNormal            = "normal"
+	protectionDangerous         = "dangerous"
+	protectionSignature         = "signature"
+	protectionSignatureOrSystem = "signatureOrSystem"
+	protectionInternal          = "internal"
+)
+
+// protectionLevelNames maps the base of android:protectionLevel, its low
+// four bits, to the level's name
+var protectionLevelNames = map[int]string{
+	0: protectionNormal,
+	1: protectionDangerous,
+	2: protectionSignature,
+	3: protectionSignatureOrSystem,
+	4: protectionInternal,
+}
+
+// platformSignaturePermissions are framework and Play services permissions
+// that only the platform or Google signed apps hold. Every
+// android.permission.BIND_* permission is signature protected as well.
+var platformSignaturePermissions = map[string]bool{
+	"android.permission.BROADCAST_PACKAGE_REMOVED":                              true,
+	"android.permission.BROADCAST_SMS":                                          true,
+	"android.permission.BROADCAST_WAP_PUSH":                                     true,
+	"android.permission.DUMP":                                                   true,
+	"android.permission.INSTALL_PACKAGES":                                       true,
+	"android.permission.MANAGE_DOCUMENTS":                                       true,
+	"android.permission.SEND_RESPOND_VIA_MESSAGE":                               true,
+	"android.permission.STATUS_BAR_SERVICE":                                     true,
+	"android.permission.WRITE_SECURE_SETTINGS":                                  true,
+	"com.google.android.c2dm.permission.SEND":                                   true,
+	"com.google.android.gms.permission.BIND_NETWORK_TASK_SERVICE":               true,
+	"com.google.android.gms.auth.api.signin.permission.REVOCATION_NOTIFICATION": true,
+}
+
+// permissionLevels maps permission names to the name of their base
+// protection level
+type permissionLevels map[string]string
+
+// declaredPermissionLevels collects the protection levels of the permissions
+// the app declares. The <permission> elements of the manifest win over the
+// Permissions and PermissionsProtectionLevel lists of the metadata.
+func declaredPermissionLevels(manifest *XMLElement, metadata *models.MetaDataModel) permissionLevels {
+	levels := permissionLevels{}
+
+	permissions := metadata.AndroidManifest.Permissions
+	protection := metadata.AndroidManifest.PermissionsProtectionLevel
+	if len(permissions) == len(protection) {
+		for i, permission := range permissions {
+			if level := parseProtectionLevel(protection[i]); level != "" {
+				levels[permission] = level
+			}
+		}
+	}
+
+	for _, element := range manifest.ChildrenNamed("permission") {
+		name := element.AndroidString("name")
+		if name == "" {
+			continue
+		}
+		attr := element.AndroidAttr("protectionLevel")
+		switch value, ok := attr.Int(); {
+		case attr == nil:
+			levels[name] = protectionNormal
+		case ok:
+			levels[name] = protectionLevelNames[value&0xf]
+		default:
+			levels[name] = parseProtectionLevel(attr.Value)
+		}
+	}
+
+	return levels
+}
+
+// parseProtectionLevel returns the base level of a protection level written
+// as a number ("0x2") or as flags ("signature|privileged"), or "" when the
+// value is not understood
+func parseProtectionLevel(value string) string {
+	value = strings.TrimSpace(value)
+	if number, err := strconv.ParseInt(value, 0, 32); err == nil {
+		return protectionLevelNames[int(number)&0xf]
+	}
+
+	base := strings.ToLower(strings.TrimSpace(strings.Split(value, "|")[0]))
+	for _, level := range protectionLevelNames {
+		if strings.ToLower(level) == base {
+			return level
+		}
+	}
+	return ""
+}
+
+// level returns the base protection level of a permission, and false when
+// neither the app nor the platform table knows it
+func (levels permissionLevels) level(permission string) (string, bool) {
+	if level, ok := levels[permission]; ok && level != "" {
+		return level, true
+	}
+	if strings.HasPrefix(permission, "android.permission.BIND_") || platformSignaturePermissions[permission] {
+		return protectionSignature, true
+	}
+	return "", false
+}
+
+// guard labels a single permission guard
+func (levels permissionLevels) guard(permission string) (string, string) {
+	if permission == "" {
+		return models.ComponentUnprotected, "no permission"
+	}
+
+	level, known := levels.level(permission)
+	switch {
+	case !known:
+		return models.ComponentWeaklyProtected, fmt.Sprintf("%s is not declared by the app, so its protection level is unknown and another app may define it", permission)
+	case level == protectionNormal:
+		return models.ComponentWeaklyProtected, fmt.Sprintf("%s is a normal permission any app can request", permission)
+	case level == protectionDangerous:
+		return models.ComponentWeaklyProtected, fmt.Sprintf("%s is a dangerous permission any app can hold once the user grants it", permission)
+	default:
+		return models.ComponentProtected, fmt.Sprintf("%s is a %s permission", permission, level)
+	}
+}
+
+// componentProtection labels an activity, service or receiver by its
+// permission guard. Components that are not exported are protected.
+func (levels permissionLevels) componentProtection(exported bool, permission string) (string, string) {
+	if !exported {
+		return models.ComponentProtected, "not exported"
+	}
+	if permission == "" {
+		return models.ComponentUnprotected, "exported without a permission"
+	}
+	return levels.guard(permission)
+}
+
+// protectionRank orders the protection labels from weakest to strongest
+var protectionRank = map[string]int{
+	models.ComponentUnprotected:     0,
+	models.ComponentWeaklyProtected: 1,
+	models.ComponentProtected:       2,
+}
+
+// providerProtection labels a content provider by the weakest of its read,
+// write and path permission guards. A path permission grants access to its
+// paths on top of the provider wide permissions, so it can only weaken them.
+func (levels permissionLevels) providerProtection(provider models.ManifestProviderInfo) (string, string) {
+	if !provider.Exported {
+		return models.ComponentProtected, "not exported"
+	}
+
+	type access struct {
+		operation  string
+		permission string
+	}
+	accesses := []access{
+		{"read", firstNonEmpty(provider.ReadPermission, provider.Permission)},
+		{"write", firstNonEmpty(provider.WritePermission, provider.Permission)},
+	}
+	for _, pathPermission := range provider.PathPermissions {
+		path := pathPermission.Path
+		if pathPermission.PathPrefix != "" {
+			path = pathPermission.PathPrefix + "*"
+		} else if pathPermission.PathPattern != "" {
+			path = pathPermission.PathPattern
+		}
+		if permission := firstNonEmpty(pathPermission.ReadPermission, pathPermission.Permission); permission != "" {
+			accesses = append(accesses, access{"read of " + path, permission})
+		}
+		if permission := firstNonEmpty(pathPermission.WritePermission, pathPermission.Permission); permission != "" {
+			accesses = append(accesses, access{"write of " + path, permission})
+		}
+	}
+
+	protection := models.ComponentProtected
+	var details []string
+	for _, access := range accesses {
+		label, detail := levels.guard(access.permission)
+		if protectionRank[label] < protectionRank[protection] {
+			protection = label
+		}
+		details = append(details, access.operation+": "+detail)
+	}
+	if provider.GrantURIPermissions || len(provider.GrantURIPaths) > 0 {
+		details = append(details, "the app can grant temporary access to individual URIs")
+	}
+
+	return protection, strings.Join(details, "; ")
+}
+
+// firstNonEmpty returns the first of values that is not empty
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,143 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"morf/models"
+	"strings"
+	"testing"
+)
+
+func TestDeclaredPermissionLevels(t *testing.T) {
+	manifest := &XMLElement{Name: "manifest", Children: []*XMLElement{
+		{Name: "permission", Attributes: []XMLAttribute{
+			testAttr("name", 0x01010003, AttrTypeString, 0, "com.example.SIGNED"),
+			testAttr("protectionLevel", 0x01010009, AttrTypeIntHex, 0x12, "0x12"),
+		}},
+		{Name: "permission", Attributes: []XMLAttribute{
+			testAttr("name", 0x01010003, AttrTypeString, 0, "com.example.OPEN"),
+		}},
+	}}
+	var metadata models.MetaDataModel
+	metadata.AndroidManifest.Permissions = models.JSONStringArray{"com.example.SIGNED", "com.example.USER"}
+	metadata.AndroidManifest.PermissionsProtectionLevel = models.JSONStringArray{"normal", "dangerous"}
+
+	levels := declaredPermissionLevels(manifest, &metadata)
+	want := map[string]string{
+		"com.example.SIGNED":                  protectionSignature, // the manifest wins over the metadata
+		"com.example.OPEN":                    protectionNormal,
+		"com.example.USER":                    protectionDangerous,
+		"android.permission.BIND_JOB_SERVICE": protectionSignature,
+	}
+	for permission, level := range want {
+		if got, _ := levels.level(permission); got != level {
+			t.Errorf("%s: got level %q, want %q", permission, got, level)
+		}
+	}
+	if _, known := levels.level("com.other.app.PERMISSION"); known {
+		t.Error("undeclared permission should be unknown")
+	}
+
+	for value, level := range map[string]string{"0x3": protectionSignatureOrSystem, "signature|privileged": protectionSignature, "bogus": ""} {
+		if got := parseProtectionLevel(value); got != level {
+			t.Errorf("parseProtectionLevel(%q) = %q, want %q", value, got, level)
+		}
+	}
+}
+
+func TestComponentProtection(t *testing.T) {
+	application := &XMLElement{Name: "application", Attributes: []XMLAttribute{
+		testAttr("permission", 0x01010006, AttrTypeString, 0, "com.example.OPEN"),
+	}, Children: []*XMLElement{
+		{Name: "service", Attributes: []XMLAttribute{
+			testAttr("name", 0x01010003, AttrTypeString, 0, "com.example.JobService"),
+			testAttr("exported", 0x01010010, AttrTypeIntBoolean, 0xffffffff, "true"),
+			testAttr("permission", 0x01010006, AttrTypeString, 0, "android.permission.BIND_JOB_SERVICE"),
+		}},
+		// Inherits the application wide permission
+		{Name: "service", Attributes: []XMLAttribute{
+			testAttr("name", 0x01010003, AttrTypeString, 0, "com.example.SyncService"),
+			testAttr("exported", 0x01010010, AttrTypeIntBoolean, 0xffffffff, "true"),
+		}},
+	}}
+	levels := permissionLevels{"com.example.OPEN": protectionNormal}
+
+	services := extractServices(application, 34)
+	if len(services) != 2 || services[1].Permission != "com.example.OPEN" {
+		t.Fatalf("unexpected services: %+v", services)
+	}
+	for i, want := range []string{models.ComponentProtected, models.ComponentWeaklyProtected} {
+		if got, detail := levels.componentProtection(services[i].Exported, services[i].Permission); got != want {
+			t.Errorf("%s: got %q (%s), want %q", services[i].Name, got, detail, want)
+		}
+	}
+
+	if got, _ := levels.componentProtection(true, ""); got != models.ComponentUnprotected {
+		t.Errorf("exported without permission: got %q", got)
+	}
+	if got, _ := levels.componentProtection(false, ""); got != models.ComponentProtected {
+		t.Errorf("not exported: got %q", got)
+	}
+	if got, _ := levels.componentProtection(true, "com.other.app.PERMISSION"); got != models.ComponentWeaklyProtected {
+		t.Errorf("undeclared permission: got %q", got)
+	}
+}
+
+func TestProviderProtection(t *testing.T) {
+	application := &XMLElement{Name: "application", Children: []*XMLElement{
+		{Name: "provider", Attributes: []XMLAttribute{
+			testAttr("name", 0x01010003, AttrTypeString, 0, "com.example.DataProvider"),
+			testAttr("exported", 0x01010010, AttrTypeIntBoolean, 0xffffffff, "true"),
+			testAttr("readPermission", 0x01010007, AttrTypeString, 0, "com.example.SIGNED"),
+			testAttr("writePermission", 0x01010008, AttrTypeString, 0, "com.example.SIGNED"),
+			testAttr("grantUriPermissions", 0x0101001b, AttrTypeIntBoolean, 0, "false"),
+		}, Children: []*XMLElement{
+			{Name: "path-permission", Attributes: []XMLAttribute{
+				testAttr("pathPrefix", 0x0101002b, AttrTypeString, 0, "/public"),
+				testAttr("readPermission", 0x01010007, AttrTypeString, 0, "com.example.OPEN"),
+			}},
+			{Name: "grant-uri-permission", Attributes: []XMLAttribute{
+				testAttr("path", 0x0101002a, AttrTypeString, 0, "/shared"),
+			}},
+		}},
+	}}
+	levels := permissionLevels{"com.example.SIGNED": protectionSignature, "com.example.OPEN": protectionNormal}
+
+	providers := extractProviders(application, 34)
+	if len(providers) != 1 {
+		t.Fatalf("got %d providers, want 1", len(providers))
+	}
+	provider := providers[0]
+	if len(provider.PathPermissions) != 1 || provider.PathPermissions[0].PathPrefix != "/public" || len(provider.GrantURIPaths) != 1 {
+		t.Errorf("unexpected provider guards: %+v", provider)
+	}
+
+	// The path permission opens reads of /public to any app
+	protection, detail := levels.providerProtection(provider)
+	if protection != models.ComponentWeaklyProtected || !strings.Contains(detail, "read of /public*") {
+		t.Errorf("got %q (%s), want weakly protected by the path permission", protection, detail)
+	}
+
+	provider.PathPermissions = nil
+	if protection, detail := levels.providerProtection(provider); protection != models.ComponentProtected {
+		t.Errorf("got %q (%s), want protected", protection, detail)
+	}
+	provider.WritePermission = ""
+	if protection, _ := levels.providerProtection(provider); protection != models.ComponentUnprotected {
+		t.Errorf("got %q, want unprotected writes", protection)
+	}
+}
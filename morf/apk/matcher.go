@@ -0,0 +1,608 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"morf/models"
+	"morf/utils"
+	"os"
+	"path/filepath"
+	"regexp"
+	"regexp/syntax"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxScanFileSize caps the size of a single file read by the matcher
+const maxScanFileSize = 64 << 20
+
+// minKeywordLength is the shortest literal worth using as a prefilter keyword
+const minKeywordLength = 3
+
+// compiledPattern is a secret pattern ready to be evaluated by the matcher
+type compiledPattern struct {
+	PatternDefinition
+	regex        *regexp.Regexp
+	keywords     []string // lower-cased, any one of them must be present
+	allowlist    []*regexp.Regexp
+	stopwords    []string // lower-cased
+	paths        []*regexp.Regexp
+	excludePaths []*regexp.Regexp
+	validator    SecretValidator
+}
+
+// SecretMatcher evaluates every secret pattern against a file tree in a single walk
+type SecretMatcher struct {
+	patterns []compiledPattern
+	decoding *DecodeConfig
+}
+
+// NewSecretMatcher compiles the given pattern sets into a matcher.
+// Patterns whose regex does not compile under RE2 are logged and skipped.
+func NewSecretMatcher(patternSets ...SecretPatterns) *SecretMatcher {
+	matcher := &SecretMatcher{}
+	for _, set := range patternSets {
+		for _, entry := range set.Patterns {
+			pattern, err := compilePattern(entry.Pattern)
+			if err != nil {
+				log.Errorf("Skipping pattern %s: %v", entry.Pattern.Name, err)
+				continue
+			}
+			matcher.patterns = append(matcher.patterns, pattern)
+		}
+	}
+	log.Infof("Secret matcher loaded with %d patterns", len(matcher.patterns))
+	return matcher
+}
+
+// compilePattern compiles a single pattern definition, its allowlist and path filters
+func compilePattern(definition PatternDefinition) (compiledPattern, error) {
+	// ^ and $ match at line boundaries, mirroring ripgrep's default behaviour
+	regex, err := regexp.Compile("(?m)" + definition.Regex)
+	if err != nil {
+		return compiledPattern{}, err
+	}
+	if definition.SecretGroup < 0 || definition.SecretGroup > regex.NumSubexp() {
+		return compiledPattern{}, fmt.Errorf("secretGroup %d out of range, regex has %d groups",
+			definition.SecretGroup, regex.NumSubexp())
+	}
+
+	pattern := compiledPattern{
+		PatternDefinition: definition,
+		regex:             regex,
+		keywords:          prefilterKeywords(definition.Regex),
+	}
+	pattern.Severity = normalizeSeverity(definition.Name, definition.Severity)
+
+	// Explicit keywords replace the ones derived from the regex
+	if len(definition.Keywords) > 0 {
+		pattern.keywords = nil
+		for _, keyword := range definition.Keywords {
+			pattern.keywords = append(pattern.keywords, strings.ToLower(keyword))
+		}
+	}
+
+	for _, expr := range definition.Allowlist.Regexes {
+		allow, err := regexp.Compile(expr)
+		if err != nil {
+			return compiledPattern{}, fmt.Errorf("invalid allowlist regex %q: %v", expr, err)
+		}
+		pattern.allowlist = append(pattern.allowlist, allow)
+	}
+	for _, stopword := range definition.Allowlist.Stopwords {
+		pattern.stopwords = append(pattern.stopwords, strings.ToLower(stopword))
+	}
+
+	if pattern.paths, err = compilePathGlobs(definition.Paths); err != nil {
+		return compiledPattern{}, err
+	}
+	if pattern.excludePaths, err = compilePathGlobs(definition.ExcludePaths); err != nil {
+		return compiledPattern{}, err
+	}
+
+	// An unknown validator only loses the validation, not the pattern
+	if pattern.validator, err = lookupValidator(definition); err != nil {
+		log.Warnf("Pattern %s: %v", definition.Name, err)
+	}
+
+	return pattern, nil
+}
+
+// PatternCount returns the number of patterns the matcher evaluates
+func (m *SecretMatcher) PatternCount() int {
+	return len(m.patterns)
+}
+
+// ScanDir walks root once and evaluates all patterns against every text file in it
+func (m *SecretMatcher) ScanDir(root string) []models.SecretModel {
+	paths := make(chan string, 256)
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results []models.SecretModel
+	)
+
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				content, err := os.ReadFile(path)
+				if err != nil {
+					log.Debugf("Unable to read %s: %v", path, err)
+					continue
+				}
+
+				relPath, err := filepath.Rel(root, path)
+				if err != nil {
+					relPath = path
+				}
+
+				findings := m.scanContent(path, relPath, content)
+				if len(findings) == 0 {
+					continue
+				}
+
+				mu.Lock()
+				results = append(results, findings...)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	walkErr := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			log.Debugf("Skipping %s: %v", path, err)
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || info.Size() > maxScanFileSize {
+			return nil
+		}
+
+		paths <- path
+		return nil
+	})
+	close(paths)
+	wg.Wait()
+
+	if walkErr != nil {
+		log.Errorf("Error walking %s: %v", root, walkErr)
+	}
+
+	sortFindings(results)
+	return results
+}
+
+// ScanResourceValues evaluates all patterns against the decoded res/values*/*.xml
+// files under resDir, which the source tree only holds in binary form
+func (m *SecretMatcher) ScanResourceValues(resDir string) []models.SecretModel {
+	var findings []models.SecretModel
+	walkFiles(resDir, func(path string) {
+		if !strings.HasSuffix(path, ".xml") || !strings.HasPrefix(filepath.Base(filepath.Dir(path)), "values") {
+			return
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			log.Debugf("Unable to read %s: %v", path, err)
+			return
+		}
+
+		relPath, err := filepath.Rel(resDir, path)
+		if err != nil {
+			relPath = path
+		}
+		findings = append(findings, m.scanContent(path, relPath, content)...)
+	})
+
+	sortFindings(findings)
+	return findings
+}
+
+// ScanContent evaluates all patterns against a single file's content.
+// Binary content (anything containing a NUL byte) is skipped.
+func (m *SecretMatcher) ScanContent(path string, content []byte) []models.SecretModel {
+	return m.scanContent(path, path, content)
+}
+
+// scanContent matches content reported as path, applying path filters to relPath
+func (m *SecretMatcher) scanContent(path string, relPath string, content []byte) []models.SecretModel {
+	if bytes.IndexByte(content, 0) >= 0 {
+		return nil
+	}
+
+	relPath = filepath.ToSlash(relPath)
+	lines := newLineIndex(content)
+
+	findings := m.matchPatterns(path, relPath, content, lines)
+	findings = append(findings, m.matchJWTs(path, content, lines, findings)...)
+	if m.decoding != nil {
+		findings = append(findings, m.scanDecoded(path, relPath, content, lines)...)
+	}
+	return findings
+}
+
+// matchPatterns evaluates every pattern that applies to relPath against content
+func (m *SecretMatcher) matchPatterns(path string, relPath string, content []byte, lines lineIndex) []models.SecretModel {
+	lowered := bytes.ToLower(content)
+
+	var findings []models.SecretModel
+	for _, pattern := range m.patterns {
+		if !pattern.appliesTo(relPath) || !pattern.hasKeyword(lowered) {
+			continue
+		}
+
+		for _, loc := range pattern.regex.FindAllSubmatchIndex(content, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+
+			lineNo, column := lines.position(loc[0])
+			endLineNo, endColumn := lines.position(loc[1])
+			lineContent := strings.TrimSpace(string(lines.line(lineNo)))
+
+			secretString := pattern.secretAt(content, lineContent, loc)
+			if pattern.isAllowlisted(secretString) {
+				log.Debugf("Allowlisted match for pattern %s in %s:%d", pattern.Name, path, lineNo)
+				continue
+			}
+			if pattern.lacksEntropy(secretString) {
+				log.Debugf("Low entropy match for pattern %s in %s:%d", pattern.Name, path, lineNo)
+				continue
+			}
+			// The match is masked too, since the literal on the line may not be the secret itself
+			match := pattern.matchAt(content, loc)
+			logMode := utils.RedactionMode(utils.SinkLog)
+			log.Infof("Matches: %s:%d:%s", path, lineNo, utils.MaskInText(utils.MaskInText(lineContent, match, logMode), secretString, logMode))
+
+			finding := models.SecretModel{
+				Type:             pattern.Name,
+				LineNo:           lineNo,
+				ColumnNo:         column,
+				EndLineNo:        endLineNo,
+				EndColumnNo:      endColumn,
+				FileLocation:     path,
+				SecretType:       pattern.Name, // Assuming SecretType is the same as Type
+				SecretString:     secretString,
+				Match:            match,
+				SecretConfidence: pattern.Confidence,
+				Severity:         pattern.Severity,
+				CWE:              pattern.CWE,
+				Description:      pattern.Description,
+				Remediation:      pattern.Remediation,
+			}
+			applyValidation(&finding, pattern.validator)
+			findings = append(findings, finding)
+		}
+	}
+
+	return findings
+}
+
+// secretAt extracts the secret of a match: the secretGroup capture when the
+// pattern has one, otherwise the literal on the matching line. A literal that
+// does not lie inside the match, such as the key of a JSON or key=value line,
+// is not the secret, so the match itself is used instead.
+func (p compiledPattern) secretAt(content []byte, lineContent string, loc []int) string {
+	match := p.matchAt(content, loc)
+	if p.SecretGroup > 0 {
+		return match
+	}
+	if literal := extractSecret(lineContent); literal != "" && strings.Contains(match, literal) {
+		return literal
+	}
+	return match
+}
+
+// matchAt returns the text of the secretGroup capture, or of the whole match
+// when the pattern has no secret group
+func (p compiledPattern) matchAt(content []byte, loc []int) string {
+	if group := p.SecretGroup; group > 0 && loc[2*group] >= 0 {
+		return string(content[loc[2*group]:loc[2*group+1]])
+	}
+	return string(content[loc[0]:loc[1]])
+}
+
+// appliesTo reports whether the pattern's path and exclude_paths globs admit a path
+func (p compiledPattern) appliesTo(relPath string) bool {
+	for _, exclude := range p.excludePaths {
+		if exclude.MatchString(relPath) {
+			return false
+		}
+	}
+	if len(p.paths) == 0 {
+		return true
+	}
+	for _, include := range p.paths {
+		if include.MatchString(relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAllowlisted reports whether a secret matches the pattern's allowlist
+func (p compiledPattern) isAllowlisted(secret string) bool {
+	for _, allow := range p.allowlist {
+		if allow.MatchString(secret) {
+			return true
+		}
+	}
+	lowered := strings.ToLower(secret)
+	for _, stopword := range p.stopwords {
+		if strings.Contains(lowered, stopword) {
+			return true
+		}
+	}
+	return false
+}
+
+// lacksEntropy reports whether a secret falls below the pattern's minimum
+// Shannon entropy, which is only enforced when the pattern sets one
+func (p compiledPattern) lacksEntropy(secret string) bool {
+	return p.Entropy > 0 && ShannonEntropy(secret) < p.Entropy
+}
+
+// hasKeyword reports whether the lower-cased content passes the pattern's prefilter
+func (p compiledPattern) hasKeyword(lowered []byte) bool {
+	if len(p.keywords) == 0 {
+		return true
+	}
+	for _, keyword := range p.keywords {
+		if bytes.Contains(lowered, []byte(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+// prefilterKeywords derives a set of literals of which at least one must occur
+// in any text the expression matches. It returns nil when no useful set exists.
+func prefilterKeywords(expr string) []string {
+	parsed, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+
+	keywords := requiredLiterals(parsed.Simplify())
+	for _, keyword := range keywords {
+		if utf8.RuneCountInString(keyword) < minKeywordLength {
+			return nil
+		}
+	}
+	return keywords
+}
+
+// maxLiteralSet bounds how many alternatives a prefilter keyword set may hold
+const maxLiteralSet = 64
+
+// maxClassExpansion bounds the size of a character class expanded into literals
+const maxClassExpansion = 8
+
+// requiredLiterals walks the regex syntax tree collecting mandatory literals
+func requiredLiterals(re *syntax.Regexp) []string {
+	if exact, ok := exactLiterals(re); ok && shortestLength(exact) > 0 {
+		return exact
+	}
+
+	switch re.Op {
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return requiredLiterals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		// Any mandatory part of the concatenation will do, prefer the most selective one.
+		// Runs of adjacent exact parts are joined so "gh[opu]_" yields ghp_, gho_ and ghu_.
+		var best, run []string
+		consider := func(candidate []string) {
+			if candidate != nil && shortestLength(candidate) > shortestLength(best) {
+				best = candidate
+			}
+		}
+		for _, sub := range re.Sub {
+			if exact, ok := exactLiterals(sub); ok {
+				if joined, ok := crossProduct(run, exact); ok {
+					run = joined
+					continue
+				}
+				consider(run)
+				run = exact
+				continue
+			}
+			consider(run)
+			run = nil
+			consider(requiredLiterals(sub))
+		}
+		consider(run)
+		return best
+	case syntax.OpAlternate:
+		// Every branch must contribute, otherwise the alternation can match without a literal
+		var union []string
+		for _, sub := range re.Sub {
+			candidate := requiredLiterals(sub)
+			if candidate == nil {
+				return nil
+			}
+			union = append(union, candidate...)
+		}
+		return uniqueStrings(union)
+	}
+	return nil
+}
+
+// exactLiterals returns the complete, small set of strings a node can match
+func exactLiterals(re *syntax.Regexp) ([]string, bool) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{strings.ToLower(string(re.Rune))}, true
+	case syntax.OpEmptyMatch:
+		return []string{""}, true
+	case syntax.OpCharClass:
+		var runes []string
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			for r := re.Rune[i]; r <= re.Rune[i+1]; r++ {
+				if len(runes) >= maxClassExpansion {
+					return nil, false
+				}
+				runes = append(runes, strings.ToLower(string(r)))
+			}
+		}
+		return uniqueStrings(runes), true
+	case syntax.OpCapture:
+		return exactLiterals(re.Sub[0])
+	case syntax.OpQuest:
+		sub, ok := exactLiterals(re.Sub[0])
+		if !ok {
+			return nil, false
+		}
+		return uniqueStrings(append(sub, "")), true
+	case syntax.OpConcat:
+		result := []string{""}
+		for _, sub := range re.Sub {
+			exact, ok := exactLiterals(sub)
+			if !ok {
+				return nil, false
+			}
+			if result, ok = crossProduct(result, exact); !ok {
+				return nil, false
+			}
+		}
+		return result, true
+	case syntax.OpAlternate:
+		var union []string
+		for _, sub := range re.Sub {
+			exact, ok := exactLiterals(sub)
+			if !ok {
+				return nil, false
+			}
+			union = append(union, exact...)
+		}
+		if len(union) > maxLiteralSet {
+			return nil, false
+		}
+		return uniqueStrings(union), true
+	}
+	return nil, false
+}
+
+// crossProduct concatenates every prefix with every suffix, bounded by maxLiteralSet
+func crossProduct(prefixes []string, suffixes []string) ([]string, bool) {
+	if prefixes == nil {
+		return suffixes, true
+	}
+	if len(prefixes)*len(suffixes) > maxLiteralSet {
+		return nil, false
+	}
+	var joined []string
+	for _, prefix := range prefixes {
+		for _, suffix := range suffixes {
+			joined = append(joined, prefix+suffix)
+		}
+	}
+	return uniqueStrings(joined), true
+}
+
+// uniqueStrings removes duplicates while preserving order
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// shortestLength returns the length of the shortest keyword in the set
+func shortestLength(keywords []string) int {
+	if len(keywords) == 0 {
+		return 0
+	}
+	shortest := len(keywords[0])
+	for _, keyword := range keywords[1:] {
+		if len(keyword) < shortest {
+			shortest = len(keyword)
+		}
+	}
+	return shortest
+}
+
+// lineIndex maps byte offsets of a file to 1-based line and column numbers
+type lineIndex struct {
+	content []byte
+	starts  []int
+}
+
+func newLineIndex(content []byte) lineIndex {
+	starts := []int{0}
+	for i, b := range content {
+		if b == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return lineIndex{content: content, starts: starts}
+}
+
+// position returns the 1-based line and byte column of an offset
+func (l lineIndex) position(offset int) (int, int) {
+	line := sort.Search(len(l.starts), func(i int) bool { return l.starts[i] > offset }) - 1
+	return line + 1, offset - l.starts[line] + 1
+}
+
+// line returns the content of a 1-based line without its line terminator
+func (l lineIndex) line(lineNo int) []byte {
+	start := l.starts[lineNo-1]
+	end := len(l.content)
+	if lineNo < len(l.starts) {
+		end = l.starts[lineNo] - 1
+	}
+	return bytes.TrimRight(l.content[start:end], "\r")
+}
+
+// sortFindings orders findings by location so output no longer depends on scheduling
+func sortFindings(findings []models.SecretModel) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.FileLocation != b.FileLocation {
+			return a.FileLocation < b.FileLocation
+		}
+		if a.LineNo != b.LineNo {
+			return a.LineNo < b.LineNo
+		}
+		if a.ColumnNo != b.ColumnNo {
+			return a.ColumnNo < b.ColumnNo
+		}
+		return a.Type < b.Type
+	})
+}
@@ -15,157 +15,91 @@ limitations under the License.
 */package apk
 
 import (
+	"archive/zip"
 	"morf/models"
-	"morf/utils"
 	util "morf/utils"
 	"regexp"
-	"strings"
+	"sort"
+	"strconv"
 
 	log "github.com/sirupsen/logrus"
 )
 
-func ExtractPackageData(apkPath string) models.PackageDataModel {
+// Platform versions at which aapt assumes support for more screen sizes
+const (
+	sdkDonut       = 4
+	sdkGingerbread = 9
+)
+
+// densityQualifiers maps resource directory qualifiers to the dpi values aapt reports
+var densityQualifiers = map[string]int{
+	"ldpi":    120,
+	"mdpi":    160,
+	"tvdpi":   213,
+	"hdpi":    240,
+	"xhdpi":   320,
+	"xxhdpi":  480,
+	"xxxhdpi": 640,
+	"anydpi":  65534,
+	"nodpi":   65535,
+}
 
-	// Try using system aapt first
-	aapt_success := string("")
-	aapt_error := error(nil)
-	aapt_success, aapt_error = utils.ExecuteCommand("aapt", "dump", "badging", apkPath)
+var (
+	densityQualifierRegex = regexp.MustCompile(`^res/[a-z]+(?:-[^/]*)?-(l|m|tv|h|xh|xxh|xxxh|any|no)dpi(?:-[^/]*)?/`)
+	nativeLibRegex        = regexp.MustCompile(`^lib/([^/]+)/[^/]+\.so$`)
+)
 
-	if aapt_error != nil {
+// ExtractPackageData reads the package details aapt dump badging reports
+// from the binary manifest and the entries of the APK
+func ExtractPackageData(apkPath string) models.PackageDataModel {
+	reader, err := zip.OpenReader(apkPath)
+	if err != nil {
 		log.Error("Error while getting APK version etc")
-		log.Error(aapt_error)
-		log.Error("AAPT output: " + aapt_success)
+		log.Error(err)
 		return models.PackageDataModel{} // Return empty model on error
 	}
+	defer reader.Close()
 
-	log.Info("AAPT output length: ", len(aapt_success))
-	log.Debug("AAPT raw output: " + aapt_success)
-
-	// Initialize variables
-	package_name := ""
-	version_code := ""
-	version_name := ""
-	complie_sdk_version := ""
-	sdk_version := ""
-	target_sdk := ""
-	support_screens := []string{}
-	densities := []string{}
-	native_code := []string{}
-
-	// Parse the AAPT output line by line
-	lines := strings.Split(aapt_success, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-
-		// Extract package info
-		if strings.HasPrefix(line, "package:") {
-			// Extract package name
-			nameMatch := regexp.MustCompile(`package:.*?name='([^']+)'`).FindStringSubmatch(line)
-			if len(nameMatch) > 1 {
-				package_name = nameMatch[1]
-				log.Debug("Found package name: " + package_name)
-			} else {
-				log.Warn("Package name not found in line: " + line)
-			}
-
-			// Extract version code
-			versionCodeMatch := regexp.MustCompile(`package:.*?versionCode='([^']+)'`).FindStringSubmatch(line)
-			if len(versionCodeMatch) > 1 {
-				version_code = versionCodeMatch[1]
-				log.Debug("Found version code: " + version_code)
-			} else {
-				log.Warn("Version code not found in line: " + line)
-			}
-
-			// Extract version name
-			versionNameMatch := regexp.MustCompile(`package:.*?versionName='([^']+)'`).FindStringSubmatch(line)
-			if len(versionNameMatch) > 1 {
-				version_name = versionNameMatch[1]
-				log.Debug("Found version name: " + version_name)
-			} else {
-				log.Warn("Version name not found in line: " + line)
-			}
-
-			// Extract compile SDK version
-			compileSdkMatch := regexp.MustCompile(`package:.*?compileSdkVersion='([^']+)'`).FindStringSubmatch(line)
-			if len(compileSdkMatch) > 1 {
-				complie_sdk_version = compileSdkMatch[1]
-				log.Debug("Found compile SDK version: " + complie_sdk_version)
-			} else {
-				log.Warn("Compile SDK version not found in line: " + line)
-			}
-		}
-
-		// Extract SDK version
-		if strings.HasPrefix(line, "sdkVersion:") {
-			sdkMatch := regexp.MustCompile(`sdkVersion:'([^']+)'`).FindStringSubmatch(line)
-			if len(sdkMatch) > 1 {
-				sdk_version = sdkMatch[1]
-				log.Debug("Found SDK version: " + sdk_version)
-			} else {
-				log.Warn("SDK version not found in line: " + line)
-			}
-		}
+	manifest, err := readZippedManifest(&reader.Reader)
+	if err != nil {
+		log.Error("Error while getting APK version etc")
+		log.Error(err)
+		return models.PackageDataModel{} // Return empty model on error
+	}
 
-		// Extract target SDK version
-		if strings.HasPrefix(line, "targetSdkVersion:") {
-			targetSdkMatch := regexp.MustCompile(`targetSdkVersion:'([^']+)'`).FindStringSubmatch(line)
-			if len(targetSdkMatch) > 1 {
-				target_sdk = targetSdkMatch[1]
-				log.Debug("Found target SDK version: " + target_sdk)
-			} else {
-				log.Warn("Target SDK version not found in line: " + line)
-			}
-		}
+	usesSdk := manifest.Child("uses-sdk")
+	sdk_version := usesSdk.AndroidString("minSdkVersion")
+	// aapt reports the minimum SDK as the target when none is declared
+	target_sdk := usesSdk.AndroidString("targetSdkVersion")
+	if target_sdk == "" {
+		target_sdk = sdk_version
+	}
 
-		// Extract supported screens
-		if strings.HasPrefix(line, "supports-screens:") {
-			screensStr := strings.TrimPrefix(line, "supports-screens:")
-			screens := []string{}
-			screenMatches := regexp.MustCompile(`'([^']+)'`).FindAllStringSubmatch(screensStr, -1)
-			for _, match := range screenMatches {
-				if len(match) > 1 {
-					screens = append(screens, match[1])
-				}
-			}
-			support_screens = screens
-			log.Debug("Found supported screens: " + strings.Join(support_screens, ", "))
+	compile_sdk_version := manifest.AndroidString("compileSdkVersion")
+	if compile_sdk_version == "" {
+		if platform := manifest.Attr("", "platformBuildVersionCode"); platform != nil {
+			compile_sdk_version = platform.Value
 		}
+	}
 
-		// Extract densities
-		if strings.HasPrefix(line, "densities:") {
-			densitiesStr := strings.TrimPrefix(line, "densities:")
-			dens := []string{}
-			densityMatches := regexp.MustCompile(`'([^']+)'`).FindAllStringSubmatch(densitiesStr, -1)
-			for _, match := range densityMatches {
-				if len(match) > 1 {
-					dens = append(dens, match[1])
-				}
-			}
-			densities = dens
-			log.Debug("Found densities: " + strings.Join(densities, ", "))
-		}
+	package_name := ""
+	if name := manifest.Attr("", "package"); name != nil {
+		package_name = name.Value
+	}
+	targetSdk, _ := strconv.Atoi(target_sdk)
 
-		// Extract native code
-		if strings.HasPrefix(line, "native-code:") {
-			nativeCodeStr := strings.TrimPrefix(line, "native-code:")
-			codes := []string{}
-			codeMatches := regexp.MustCompile(`'([^']+)'`).FindAllStringSubmatch(nativeCodeStr, -1)
-			for _, match := range codeMatches {
-				if len(match) > 1 {
-					codes = append(codes, match[1])
-				}
-			}
-			native_code = codes
-			log.Debug("Found native code: " + strings.Join(native_code, ", "))
-		}
+	// A version name left as a resource ID is no version name
+	version_name := manifest.AndroidString("versionName")
+	if attr := manifest.AndroidAttr("versionName"); attr != nil && attr.Type == AttrTypeReference {
+		log.Warnf("versionName %s could not be resolved through %s", version_name, resourcesEntry)
+		version_name = ""
 	}
 
 	log.Info("Extracted package info:")
 	log.Info("Package name: ", package_name)
-	log.Info("Version code: ", version_code)
+	log.Info("Version code: ", manifest.AndroidString("versionCode"))
 	log.Info("Version name: ", version_name)
-	log.Info("Compile SDK version: ", complie_sdk_version)
+	log.Info("Compile SDK version: ", compile_sdk_version)
 	log.Info("SDK version: ", sdk_version)
 	log.Info("Target SDK: ", target_sdk)
 
@@ -173,16 +107,79 @@ func ExtractPackageData(apkPath string) models.PackageDataModel {
 		PackageDataID:     0,
 		APKHash:           util.ExtractHash(apkPath),
 		PackageName:       package_name,
-		VersionCode:       version_code,
+		VersionCode:       manifest.AndroidString("versionCode"),
 		VersionName:       version_name,
-		CompileSdkVersion: complie_sdk_version,
+		CompileSdkVersion: compile_sdk_version,
 		SdkVersion:        sdk_version,
 		TargetSdk:         target_sdk,
 		MinSDK:            sdk_version, // Use SDK version as min SDK for now
-		SupportScreens:    models.JSONStringArray(support_screens),
-		Densities:         models.JSONStringArray(densities),
-		NativeCode:        models.JSONStringArray(native_code),
+		SupportScreens:    models.JSONStringArray(supportedScreens(manifest.Child("supports-screens"), targetSdk)),
+		Densities:         models.JSONStringArray(apkDensities(reader.File)),
+		NativeCode:        models.JSONStringArray(apkNativeCode(reader.File)),
 	}
 	log.Infof("Package Data: %+v", packageModel)
 	return packageModel
 }
+
+// supportedScreens lists the screen sizes the app supports, using the
+// defaults aapt derives from the target SDK for sizes <supports-screens> leaves out
+func supportedScreens(supportsScreens *XMLElement, targetSdk int) []string {
+	sizes := []struct {
+		name      string
+		attribute string
+		byDefault bool
+	}{
+		{"small", "smallScreens", targetSdk >= sdkDonut},
+		{"normal", "normalScreens", true},
+		{"large", "largeScreens", targetSdk >= sdkDonut},
+		{"xlarge", "xlargeScreens", targetSdk >= sdkGingerbread},
+	}
+
+	screens := []string{}
+	for _, size := range sizes {
+		supported, declared := supportsScreens.AndroidAttr(size.attribute).Bool()
+		if !declared {
+			supported = size.byDefault
+		}
+		if supported {
+			screens = append(screens, size.name)
+		}
+	}
+	return screens
+}
+
+// apkDensities lists the screen densities the APK has resources for, in dpi
+func apkDensities(files []*zip.File) []string {
+	found := make(map[int]bool)
+	for _, file := range files {
+		if match := densityQualifierRegex.FindStringSubmatch(file.Name); match != nil {
+			found[densityQualifiers[match[1]+"dpi"]] = true
+		}
+	}
+
+	var dpis []int
+	for dpi := range found {
+		dpis = append(dpis, dpi)
+	}
+	sort.Ints(dpis)
+
+	densities := []string{}
+	for _, dpi := range dpis {
+		densities = append(densities, strconv.Itoa(dpi))
+	}
+	return densities
+}
+
+// apkNativeCode lists the ABIs the APK ships native libraries for
+func apkNativeCode(files []*zip.File) []string {
+	found := make(map[string]bool)
+	codes := []string{}
+	for _, file := range files {
+		if match := nativeLibRegex.FindStringSubmatch(file.Name); match != nil && !found[match[1]] {
+			found[match[1]] = true
+			codes = append(codes, match[1])
+		}
+	}
+	sort.Strings(codes)
+	return codes
+}
@@ -0,0 +1,166 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"fmt"
+	"morf/utils"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// patternsDir is where the YAML secret pattern files are shipped
+const patternsDir = "/app/patterns"
+
+// Severities accepted in pattern files
+var validSeverities = map[string]bool{
+	"critical": true,
+	"high":     true,
+	"medium":   true,
+	"low":      true,
+	"info":     true,
+}
+
+// SecretPatterns is the layout of a YAML pattern file
+type SecretPatterns struct {
+	Patterns []PatternEntry `yaml:"patterns"`
+}
+
+// PatternEntry wraps a single pattern definition in a pattern file
+type PatternEntry struct {
+	Pattern PatternDefinition `yaml:"pattern"`
+}
+
+// PatternDefinition describes one secret rule. Only name, regex and confidence
+// are required; the remaining fields are optional refinements.
+type PatternDefinition struct {
+	Name         string           `yaml:"name"`
+	Regex        string           `yaml:"regex"`
+	Confidence   string           `yaml:"confidence"`
+	Keywords     []string         `yaml:"keywords,omitempty"`
+	Allowlist    PatternAllowlist `yaml:"allowlist,omitempty"`
+	Paths        []string         `yaml:"paths,omitempty"`
+	ExcludePaths []string         `yaml:"exclude_paths,omitempty"`
+	Severity     string           `yaml:"severity,omitempty"`
+	CWE          string           `yaml:"cwe,omitempty"`
+	Description  string           `yaml:"description,omitempty"`
+	Remediation  string           `yaml:"remediation,omitempty"`
+	SecretGroup  int              `yaml:"secretGroup,omitempty"`
+	Entropy      float64          `yaml:"entropy,omitempty"`
+	Validator    string           `yaml:"validator,omitempty"`
+	// Examples must be reported and CounterExamples must not, see morf patterns test
+	Examples        []string `yaml:"examples,omitempty"`
+	CounterExamples []string `yaml:"counter_examples,omitempty"`
+}
+
+// PatternAllowlist lists secret values that should never be reported for a pattern
+type PatternAllowlist struct {
+	Regexes   []string `yaml:"regexes,omitempty"`
+	Stopwords []string `yaml:"stopwords,omitempty"`
+}
+
+func readPatternFile(patternFilePath string) []byte {
+	yamlFile := utils.ReadFile(utils.GetAppFS(), patternFilePath)
+	return yamlFile
+}
+
+// loadSecretPatterns reads every YAML pattern file in the patterns directory
+func loadSecretPatterns(patternDir string) []SecretPatterns {
+	var patternSets []SecretPatterns
+
+	for _, file := range utils.ReadDir(utils.GetAppFS(), patternDir) {
+		if !strings.HasSuffix(file.Name(), ".yaml") && !strings.HasSuffix(file.Name(), ".yml") {
+			continue
+		}
+
+		yamlFile := readPatternFile(filepath.Join(patternDir, file.Name()))
+		var secretPatterns SecretPatterns
+		err := yaml.Unmarshal(yamlFile, &secretPatterns)
+		if err != nil {
+			fmt.Printf("Error unmarshaling YAML file %s: %s\n", file.Name(), err)
+			continue
+		}
+		patternSets = append(patternSets, secretPatterns)
+	}
+
+	return patternSets
+}
+
+// compilePathGlobs turns path globs into regular expressions
+func compilePathGlobs(globs []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, glob := range globs {
+		regex, err := regexp.Compile(globToRegex(glob))
+		if err != nil {
+			return nil, fmt.Errorf("invalid path glob %q: %v", glob, err)
+		}
+		compiled = append(compiled, regex)
+	}
+	return compiled, nil
+}
+
+// globToRegex converts a path glob into an anchored regular expression.
+// "**" spans directories, "*" and "?" stay within one path segment, and a glob
+// without a slash is matched against the file name only.
+func globToRegex(glob string) string {
+	glob = filepath.ToSlash(glob)
+	var builder strings.Builder
+
+	builder.WriteString("^")
+	if !strings.Contains(glob, "/") {
+		builder.WriteString("(?:.*/)?")
+	}
+	glob = strings.TrimPrefix(glob, "/")
+
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					// "**/" matches zero or more leading directories
+					i++
+					builder.WriteString("(?:.*/)?")
+				} else {
+					builder.WriteString(".*")
+				}
+			} else {
+				builder.WriteString("[^/]*")
+			}
+		case '?':
+			builder.WriteString("[^/]")
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	builder.WriteString("$")
+
+	return builder.String()
+}
+
+// normalizeSeverity lower-cases a severity and warns about unknown values
+func normalizeSeverity(name string, severity string) string {
+	severity = strings.ToLower(strings.TrimSpace(severity))
+	if severity != "" && !validSeverities[severity] {
+		log.Warnf("Pattern %s has unknown severity %q", name, severity)
+	}
+	return severity
+}
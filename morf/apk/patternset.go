@@ -0,0 +1,200 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	database "morf/db"
+	"morf/models"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// PatternSet is the merged set of file-based and stored patterns used by one
+// scan, with a version that changes whenever any pattern does
+type PatternSet struct {
+	Patterns SecretPatterns
+	Version  string
+}
+
+// LoadPatternSet merges the pattern files with the patterns stored in the
+// database. It is called for every scan, so API changes apply to the next one.
+func LoadPatternSet() PatternSet {
+	return MergePatternSet(loadSecretPatterns(patternsDir), database.GetPatterns())
+}
+
+// MergePatternSet applies the stored patterns on top of the file-based ones.
+// A stored pattern replaces every file pattern of the same name, disabled
+// stored patterns remove them, and the rest are appended in name order.
+func MergePatternSet(fileSets []SecretPatterns, stored []models.SecretPattern) PatternSet {
+	overrides := make(map[string]models.SecretPattern, len(stored))
+	for _, pattern := range stored {
+		overrides[pattern.Name] = pattern
+	}
+
+	var merged SecretPatterns
+	replaced := make(map[string]bool)
+	for _, set := range fileSets {
+		for _, entry := range set.Patterns {
+			override, ok := overrides[entry.Pattern.Name]
+			if !ok {
+				merged.Patterns = append(merged.Patterns, entry)
+				continue
+			}
+			if !replaced[override.Name] && !override.Disabled {
+				merged.Patterns = append(merged.Patterns, PatternEntry{Pattern: PatternDefinitionFromModel(override)})
+			}
+			replaced[override.Name] = true
+		}
+	}
+
+	// Sort a copy, the caller's slice is left in its order
+	appended := append([]models.SecretPattern(nil), stored...)
+	sort.Slice(appended, func(i, j int) bool { return appended[i].Name < appended[j].Name })
+	for _, pattern := range appended {
+		if !replaced[pattern.Name] && !pattern.Disabled {
+			merged.Patterns = append(merged.Patterns, PatternEntry{Pattern: PatternDefinitionFromModel(pattern)})
+		}
+	}
+
+	return PatternSet{Patterns: merged, Version: patternSetVersion(merged)}
+}
+
+// patternSetVersion hashes the effective patterns in order
+func patternSetVersion(patterns SecretPatterns) string {
+	encoded, err := yaml.Marshal(patterns)
+	if err != nil {
+		return ""
+	}
+	hash := sha256.Sum256(encoded)
+	return hex.EncodeToString(hash[:6])
+}
+
+// ListPatterns returns every file-based and stored pattern, including the
+// disabled ones, each marked with its source
+func ListPatterns() []models.SecretPattern {
+	stored := database.GetPatterns()
+	overridden := make(map[string]bool, len(stored))
+	for _, pattern := range stored {
+		overridden[pattern.Name] = true
+	}
+
+	var patterns []models.SecretPattern
+	for _, set := range loadSecretPatterns(patternsDir) {
+		for _, entry := range set.Patterns {
+			if !overridden[entry.Pattern.Name] {
+				patterns = append(patterns, PatternModelFromDefinition(entry.Pattern))
+			}
+		}
+	}
+	for _, pattern := range stored {
+		pattern.Source = models.PatternSourceDatabase
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+// FilePattern returns the first file-based pattern with the given name
+func FilePattern(name string) (models.SecretPattern, bool) {
+	for _, set := range loadSecretPatterns(patternsDir) {
+		for _, entry := range set.Patterns {
+			if entry.Pattern.Name == name {
+				return PatternModelFromDefinition(entry.Pattern), true
+			}
+		}
+	}
+	return models.SecretPattern{}, false
+}
+
+// ValidatePattern checks that a pattern has a name, compiles under RE2, only
+// uses known severities and validators, and handles its own samples
+func ValidatePattern(pattern models.SecretPattern) error {
+	if pattern.Name == "" {
+		return errors.New("name is required")
+	}
+	if pattern.Regex == "" || pattern.Confidence == "" {
+		return errors.New("regex and confidence are required")
+	}
+	// Severities are matched the way the pattern loader normalizes them
+	if severity := strings.ToLower(strings.TrimSpace(pattern.Severity)); severity != "" && !validSeverities[severity] {
+		return fmt.Errorf("unknown severity %q", pattern.Severity)
+	}
+
+	definition := PatternDefinitionFromModel(pattern)
+	compiled, err := compilePattern(definition)
+	if err != nil {
+		return err
+	}
+	if _, err := lookupValidator(definition); err != nil {
+		return err
+	}
+	if failures := compiled.checkSamples(); len(failures) > 0 {
+		return errors.New(failures[0])
+	}
+	return nil
+}
+
+// PatternDefinitionFromModel converts a stored pattern into a pattern definition
+func PatternDefinitionFromModel(pattern models.SecretPattern) PatternDefinition {
+	return PatternDefinition{
+		Name:            pattern.Name,
+		Regex:           pattern.Regex,
+		Confidence:      pattern.Confidence,
+		Keywords:        pattern.Keywords,
+		Allowlist:       PatternAllowlist{Regexes: pattern.AllowlistRegexes, Stopwords: pattern.AllowlistStopwords},
+		Paths:           pattern.Paths,
+		ExcludePaths:    pattern.ExcludePaths,
+		Severity:        pattern.Severity,
+		CWE:             pattern.CWE,
+		Description:     pattern.Description,
+		Remediation:     pattern.Remediation,
+		SecretGroup:     pattern.SecretGroup,
+		Entropy:         pattern.Entropy,
+		Validator:       pattern.Validator,
+		Examples:        pattern.Examples,
+		CounterExamples: pattern.CounterExamples,
+	}
+}
+
+// PatternModelFromDefinition converts a file-based pattern definition into the API model
+func PatternModelFromDefinition(definition PatternDefinition) models.SecretPattern {
+	return models.SecretPattern{
+		Name:               definition.Name,
+		Regex:              definition.Regex,
+		Confidence:         definition.Confidence,
+		Keywords:           definition.Keywords,
+		AllowlistRegexes:   definition.Allowlist.Regexes,
+		AllowlistStopwords: definition.Allowlist.Stopwords,
+		Paths:              definition.Paths,
+		ExcludePaths:       definition.ExcludePaths,
+		Severity:           definition.Severity,
+		CWE:                definition.CWE,
+		Description:        definition.Description,
+		Remediation:        definition.Remediation,
+		SecretGroup:        definition.SecretGroup,
+		Entropy:            definition.Entropy,
+		Validator:          definition.Validator,
+		Examples:           definition.Examples,
+		CounterExamples:    definition.CounterExamples,
+		Source:             models.PatternSourceFile,
+	}
+}
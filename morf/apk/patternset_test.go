@@ -0,0 +1,89 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"morf/models"
+	"testing"
+)
+
+func TestMergePatternSet(t *testing.T) {
+	fileSets := []SecretPatterns{{Patterns: []PatternEntry{
+		{Pattern: PatternDefinition{Name: "AWS API Key", Regex: "AKIA[0-9A-Z]{16}", Confidence: "high"}},
+		{Pattern: PatternDefinition{Name: "Slack Token", Regex: "xox[bp]-[0-9]+", Confidence: "high"}},
+		{Pattern: PatternDefinition{Name: "Stripe API Key", Regex: "sk_live_[0-9a-zA-Z]{24}", Confidence: "high"}},
+	}}}
+	stored := []models.SecretPattern{
+		{Name: "Zendesk Token", Regex: "zd_[0-9a-f]{32}", Confidence: "medium"},
+		{Name: "Slack Token", Regex: "xox[baprs]-[0-9A-Za-z-]+", Confidence: "medium"},
+		{Name: "Stripe API Key", Regex: "sk_live_[0-9a-zA-Z]{24}", Confidence: "high", Disabled: true},
+		{Name: "Internal Key", Regex: "ik_[0-9]+", Confidence: "low", Disabled: true},
+	}
+
+	set := MergePatternSet(fileSets, stored)
+
+	var names []string
+	for _, entry := range set.Patterns.Patterns {
+		names = append(names, entry.Pattern.Name)
+	}
+	want := []string{"AWS API Key", "Slack Token", "Zendesk Token"}
+	if len(names) != len(want) {
+		t.Fatalf("patterns = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("patterns = %v, want %v", names, want)
+		}
+	}
+	if stored[0].Name != "Zendesk Token" {
+		t.Errorf("stored patterns were reordered: %+v", stored)
+	}
+	if set.Patterns.Patterns[1].Pattern.Confidence != "medium" {
+		t.Errorf("stored Slack Token did not replace the file pattern: %+v", set.Patterns.Patterns[1].Pattern)
+	}
+
+	if set.Version == "" || set.Version != MergePatternSet(fileSets, stored).Version {
+		t.Errorf("version %q is not stable", set.Version)
+	}
+	if set.Version == MergePatternSet(fileSets, nil).Version {
+		t.Error("version did not change with the stored patterns")
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern models.SecretPattern
+		valid   bool
+	}{
+		{"valid", models.SecretPattern{Name: "Key", Regex: "key_[0-9]{8}", Confidence: "high", Severity: "high"}, true},
+		{"missing name", models.SecretPattern{Regex: "key", Confidence: "high"}, false},
+		{"not RE2", models.SecretPattern{Name: "Key", Regex: "(?<=key)[0-9]+", Confidence: "high"}, false},
+		{"bad secret group", models.SecretPattern{Name: "Key", Regex: "key", Confidence: "high", SecretGroup: 2}, false},
+		{"mixed case severity", models.SecretPattern{Name: "Key", Regex: "key", Confidence: "high", Severity: "High"}, true},
+		{"unknown severity", models.SecretPattern{Name: "Key", Regex: "key", Confidence: "high", Severity: "urgent"}, false},
+		{"unknown validator", models.SecretPattern{Name: "Key", Regex: "key", Confidence: "high", Validator: "nope"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidatePattern(tt.pattern); (err == nil) != tt.valid {
+				t.Errorf("ValidatePattern error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"morf/utils"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// noiseCorpusSize is the size of the generated text every regex is timed on
+const noiseCorpusSize = 256 << 10
+
+// Defaults for flagging a pattern as a performance or false positive outlier
+const (
+	DefaultSlowPatternThreshold = 50 * time.Millisecond
+	DefaultNoiseMatchThreshold  = 5
+)
+
+// PatternTestOptions sets the outlier thresholds of RunPatternTests
+type PatternTestOptions struct {
+	// SlowThreshold flags patterns taking longer to scan the noise corpus
+	SlowThreshold time.Duration
+	// NoiseMatchThreshold flags patterns matching the noise corpus more often
+	NoiseMatchThreshold int
+}
+
+// PatternTestResult is the outcome of testing one pattern
+type PatternTestResult struct {
+	File         string
+	Pattern      string
+	Samples      int
+	Failures     []string
+	Duration     time.Duration
+	NoiseMatches int
+	Outliers     []string
+}
+
+// PatternTestReport sums up the tests of a pattern directory
+type PatternTestReport struct {
+	Files    int
+	Patterns int
+	Samples  int
+	Untested int
+	Results  []PatternTestResult
+}
+
+// Failed reports whether any pattern failed to load, compile or handle its samples
+func (r PatternTestReport) Failed() bool {
+	for _, result := range r.Results {
+		if len(result.Failures) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// RunPatternTests compiles every pattern in the YAML files of dir, checks its
+// examples and counter_examples, and times it on generated noise to find
+// slow patterns and patterns likely to flood a scan with false positives
+func RunPatternTests(dir string, options PatternTestOptions) PatternTestReport {
+	var report PatternTestReport
+	corpus := noiseCorpus()
+
+	for _, file := range utils.ReadDir(utils.GetAppFS(), dir) {
+		if !strings.HasSuffix(file.Name(), ".yaml") && !strings.HasSuffix(file.Name(), ".yml") {
+			continue
+		}
+		report.Files++
+
+		var patterns SecretPatterns
+		if err := yaml.UnmarshalStrict(readPatternFile(filepath.Join(dir, file.Name())), &patterns); err != nil {
+			report.Results = append(report.Results, PatternTestResult{File: file.Name(), Failures: []string{err.Error()}})
+			continue
+		}
+
+		for _, entry := range patterns.Patterns {
+			result := testPattern(entry.Pattern, corpus, options)
+			result.File = file.Name()
+			report.Patterns++
+			report.Samples += result.Samples
+			if result.Samples == 0 {
+				report.Untested++
+			}
+			report.Results = append(report.Results, result)
+		}
+	}
+
+	return report
+}
+
+// testPattern compiles a pattern, runs its samples and times it on the corpus
+func testPattern(definition PatternDefinition, corpus []byte, options PatternTestOptions) PatternTestResult {
+	result := PatternTestResult{
+		Pattern: definition.Name,
+		Samples: len(definition.Examples) + len(definition.CounterExamples),
+	}
+
+	if definition.Name == "" {
+		result.Failures = append(result.Failures, "pattern has no name")
+	}
+	pattern, err := compilePattern(definition)
+	if err != nil {
+		result.Failures = append(result.Failures, err.Error())
+		return result
+	}
+	if _, err := lookupValidator(definition); err != nil {
+		result.Failures = append(result.Failures, err.Error())
+	}
+	result.Failures = append(result.Failures, pattern.checkSamples()...)
+
+	start := time.Now()
+	result.NoiseMatches = len(pattern.regex.FindAllIndex(corpus, -1))
+	result.Duration = time.Since(start)
+
+	if options.SlowThreshold > 0 && result.Duration > options.SlowThreshold {
+		result.Outliers = append(result.Outliers, fmt.Sprintf("took %v to scan %d KiB", result.Duration.Round(time.Millisecond), len(corpus)>>10))
+	}
+	if options.NoiseMatchThreshold > 0 && result.NoiseMatches > options.NoiseMatchThreshold {
+		result.Outliers = append(result.Outliers, fmt.Sprintf("matched random text %d times", result.NoiseMatches))
+	}
+	return result
+}
+
+// checkSamples returns a failure for every example the pattern does not report
+// and every counter example it does
+func (p compiledPattern) checkSamples() []string {
+	var failures []string
+	for _, example := range p.Examples {
+		if !p.matchesSample(example) {
+			failures = append(failures, fmt.Sprintf("example not matched: %q", example))
+		}
+	}
+	for _, counterExample := range p.CounterExamples {
+		if p.matchesSample(counterExample) {
+			failures = append(failures, fmt.Sprintf("counter example matched: %q", counterExample))
+		}
+	}
+	return failures
+}
+
+// matchesSample reports whether the matcher would report a finding for the
+// sample, applying the keyword prefilter, allowlist and entropy but not path filters
+func (p compiledPattern) matchesSample(sample string) bool {
+	content := []byte(sample)
+	if !p.hasKeyword(bytes.ToLower(content)) {
+		return false
+	}
+
+	lines := newLineIndex(content)
+	for _, loc := range p.regex.FindAllSubmatchIndex(content, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		lineNo, _ := lines.position(loc[0])
+		secret := p.secretAt(content, strings.TrimSpace(string(lines.line(lineNo))), loc)
+		if !p.isAllowlisted(secret) && !p.lacksEntropy(secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// noiseCorpus generates smali and resource lines holding random identifiers
+// and literals. The seed is fixed so match counts are stable between runs.
+func noiseCorpus() []byte {
+	random := rand.New(rand.NewSource(1))
+	const lower = "abcdefghijklmnopqrstuvwxyz"
+	const literal = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/=_-"
+
+	word := func(alphabet string, min, max int) string {
+		value := make([]byte, min+random.Intn(max-min+1))
+		for i := range value {
+			value[i] = alphabet[random.Intn(len(alphabet))]
+		}
+		return string(value)
+	}
+
+	var corpus bytes.Buffer
+	for corpus.Len() < noiseCorpusSize {
+		switch random.Intn(4) {
+		case 0:
+			fmt.Fprintf(&corpus, "    const-string v%d, \"%s\"\n", random.Intn(16), word(literal, 8, 64))
+		case 1:
+			fmt.Fprintf(&corpus, "    invoke-virtual {v0, v1}, Lcom/%s/%s;->%s(Ljava/lang/String;)V\n", word(lower, 3, 10), word(lower, 3, 12), word(lower, 3, 16))
+		case 2:
+			fmt.Fprintf(&corpus, "    <string name=\"%s\">%s</string>\n", word(lower, 4, 20), word(literal, 4, 48))
+		default:
+			fmt.Fprintf(&corpus, ".field private static final %s:Ljava/lang/String; = \"%s\"\n", word(lower, 4, 16), word(literal, 12, 40))
+		}
+	}
+	return corpus.Bytes()
+}
@@ -0,0 +1,95 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShippedPatternSamples(t *testing.T) {
+	report := RunPatternTests("../patterns", PatternTestOptions{})
+	for _, result := range report.Results {
+		for _, failure := range result.Failures {
+			t.Errorf("%s: %s: %s", result.File, result.Pattern, failure)
+		}
+	}
+	if report.Samples == 0 {
+		t.Error("no samples found in the shipped patterns")
+	}
+}
+
+func TestRunPatternTests(t *testing.T) {
+	dir := t.TempDir()
+	patterns := `patterns:
+  - pattern:
+      name: Token
+      regex: "tok_[0-9a-f]{8}"
+      confidence: high
+      allowlist:
+        stopwords: [dead]
+      examples: ['"tok_0123abcd"']
+      counter_examples: ['"tok_deadbeef"', '"tok_1234"']
+  - pattern:
+      name: Broken Samples
+      regex: "key_[0-9]{4}"
+      confidence: high
+      examples: ['"key_12"']
+      counter_examples: ['"key_1234"']
+  - pattern:
+      name: Lookbehind
+      regex: "(?<=x)y"
+      confidence: high
+  - pattern:
+      name: Anything
+      regex: "[a-z]+"
+      confidence: low
+`
+	if err := os.WriteFile(filepath.Join(dir, "test.yml"), []byte(patterns), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "typo.yaml"), []byte("patterns:\n  - pattern:\n      name: X\n      exampels: [a]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report := RunPatternTests(dir, PatternTestOptions{NoiseMatchThreshold: DefaultNoiseMatchThreshold})
+	if !report.Failed() || report.Files != 2 || report.Patterns != 4 || report.Samples != 5 || report.Untested != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+
+	results := map[string]PatternTestResult{}
+	for _, result := range report.Results {
+		results[result.File+":"+result.Pattern] = result
+	}
+	if failures := results["test.yml:Token"].Failures; len(failures) != 0 {
+		t.Errorf("Token failures = %v", failures)
+	}
+	if failures := results["test.yml:Broken Samples"].Failures; len(failures) != 2 {
+		t.Errorf("Broken Samples failures = %v, want 2", failures)
+	}
+	if failures := results["test.yml:Lookbehind"].Failures; len(failures) != 1 {
+		t.Errorf("Lookbehind failures = %v, want a compile error", failures)
+	}
+	if outliers := results["test.yml:Anything"].Outliers; len(outliers) != 1 || !strings.Contains(outliers[0], "random text") {
+		t.Errorf("Anything outliers = %v", outliers)
+	}
+	if failures := results["typo.yaml:"].Failures; len(failures) != 1 {
+		t.Errorf("typo.yaml failures = %v, want a strict parsing error", failures)
+	}
+}
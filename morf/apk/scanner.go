@@ -19,25 +19,13 @@ import (
 	"fmt"
 	"morf/models"
 	"morf/utils"
-	"os"
-	"strconv"
+	"sort"
 	"strings"
-	"sync"
 
 	log "github.com/sirupsen/logrus"
-	"gopkg.in/yaml.v2"
+	vip "github.com/spf13/viper"
 )
 
-type SecretPatterns struct {
-	Patterns []struct {
-		Pattern struct {
-			Name       string `yaml:"name"`
-			Regex      string `yaml:"regex"`
-			Confidence string `yaml:"confidence"`
-		} `yaml:"pattern"`
-	} `yaml:"patterns"`
-}
-
 type PatternList struct {
 	Patterns []struct {
 		Pattern struct {
@@ -46,7 +34,8 @@ type PatternList struct {
 	}
 }
 
-func StartSecScan(apkPath string) []models.SecretModel {
+// StartSecScan decompiles an APK and scans it with the given patterns
+func StartSecScan(apkPath string, patterns PatternSet) []models.SecretModel {
 	counter := 0
 	log.Println("Decompiling the APK file for sources")
 	fmt.Println(apkPath)
@@ -69,100 +58,28 @@ func StartSecScan(apkPath string) []models.SecretModel {
 
 	if counter == 2 {
 		log.Println("Decompiling the APK file successful")
-		return SanitizeSecrets(StartScan())
+		return SanitizeSecrets(StartScan(patterns))
 	}
 
 	return nil
 }
 
-func readPatternFile(patternFilePath string) []byte {
-	yamlFile := utils.ReadFile(utils.GetAppFS(), patternFilePath)
-	return yamlFile
-}
-
-func StartScan() []models.SecretModel {
-	log.Info("Starting secret scan on the APK file")
-	files := utils.ReadDir(utils.GetAppFS(), "/app/patterns")
-
-	var wg sync.WaitGroup
-	resultsChan := make(chan models.SecretModel, 100)
-
-	// Create a mutex instance
-	var mu sync.Mutex
-
-	for _, file := range files {
-		if strings.HasSuffix(file.Name(), ".yaml") || strings.HasSuffix(file.Name(), ".yml") {
-			wg.Add(1)
-			go func(file os.FileInfo) {
-				defer wg.Done()
-				yamlFile := readPatternFile("/app/patterns/" + file.Name())
-				var secretPatterns SecretPatterns
-				err := yaml.Unmarshal(yamlFile, &secretPatterns)
-				if err != nil {
-					fmt.Printf("Error unmarshaling YAML file %s: %s\n", file.Name(), err)
-					return
-				}
-
-				for _, pattern := range secretPatterns.Patterns {
-					pat := pattern.Pattern.Regex
-					log.Info("Searching for pattern: " + pat)
-					result, err := utils.ExecuteCommand("rg", "-n", "-e", pat, "--multiline", utils.GetFilesDir())
-					if err != nil {
-						log.Debugf("No matches found for pattern: %s", pat)
-						continue
-					}
-
-					stdout := strings.TrimSpace(string(result))
-					if stdout != "" {
-						log.Infof("Found matches for pattern: %s", pat)
-						log.Infof("Matches: %s", stdout)
-						lines := strings.Split(stdout, "\n")
-						log.Debugf("Number of matches: %d", len(lines))
-						for _, line := range lines {
-							parts := strings.SplitN(line, ":", 3)
-							if len(parts) < 3 {
-								continue
-							}
-
-							fileName := parts[0]
-							lineNumber, err := strconv.Atoi(parts[1])
-							if err != nil {
-								log.Errorf("Error converting line number: %s\n", err)
-								continue
-							}
-
-							content := strings.TrimSpace(parts[2])
-							secretString := extractSecret(content)
-							secret := models.SecretModel{
-								Type:             pattern.Pattern.Name,
-								LineNo:           lineNumber,
-								FileLocation:     fileName,
-								SecretType:       pattern.Pattern.Name, // Assuming SecretType is the same as Type
-								SecretString:     secretString,
-								SecretConfidence: pattern.Pattern.Confidence,
-							}
-
-							resultsChan <- secret
-						}
-					}
-				}
-			}(file)
-		}
-	}
-
-	wg.Wait()
-	close(resultsChan)
+func StartScan(patterns PatternSet) []models.SecretModel {
+	log.Infof("Starting secret scan on the APK file with pattern set %s", patterns.Version)
+	matcher := NewSecretMatcher(patterns.Patterns).EnableDecoding(LoadDecodeConfig())
+	findings := matcher.ScanDir(utils.GetFilesDir())
+	findings = append(findings, matcher.ScanResourceValues(utils.GetResDir())...)
+	findings = append(findings, matcher.ScanNativeLibraries(utils.GetFilesDir())...)
+	findings = append(findings, matcher.ScanEmbeddedFiles(utils.GetFilesDir(), LoadArchiveLimits())...)
 
-	var secretModel []models.SecretModel
+	// Look for random-looking literals that no pattern knows about
+	entropyFindings := NewEntropyAnalyzer(LoadEntropyConfig()).ScanSources(utils.GetSourceDir(), utils.GetResDir())
+	findings = MergeEntropyFindings(findings, entropyFindings)
 
-	for secret := range resultsChan {
-		// Lock the critical section
-		mu.Lock()
-		secretModel = append(secretModel, secret)
-		mu.Unlock()
-	}
+	AnalyzeJWTs(findings, func() []string { return appStringLiterals(utils.GetSourceDir(), utils.GetResDir()) })
 
-	return secretModel
+	AttachContext(findings, vip.GetInt("context_lines"))
+	return findings
 }
 
 func extractSecret(content string) string {
@@ -197,24 +114,127 @@ func extractSecret(content string) string {
 	return content
 }
 
+// SanitizeSecrets groups raw matches into one finding per pattern and secret.
+// Each finding is identified by a fingerprint of its pattern and normalized
+// value and lists every location it was found at. A value found by several
+// patterns yields one finding per pattern, so adding or renaming a pattern
+// never changes the fingerprints baselines refer to.
 func SanitizeSecrets(scanner_data []models.SecretModel) []models.SecretModel {
-	var sanitizedSecrets []models.SecretModel
-	// Use a map to track unique SecretStrings
-	uniqueSecrets := make(map[string]models.SecretModel)
+	var order []string
+	groups := make(map[string][]models.SecretModel)
 
 	for _, secret := range scanner_data {
-		// If the secret is not already in uniqueSecrets, add it
-		if _, exists := uniqueSecrets[secret.SecretString]; !exists {
-			uniqueSecrets[secret.SecretString] = secret
-			sanitizedSecrets = append(sanitizedSecrets, secret) // Append the unique secret to the sanitized list
+		value := utils.NormalizeSecret(secret.SecretString)
+		if value == "" {
+			value = fmt.Sprintf("%s:%d:%d", secret.FileLocation, secret.LineNo, secret.ColumnNo)
+		}
+		key := secret.Type + "\x00" + value
+		if _, exists := groups[key]; !exists {
+			order = append(order, key)
 		}
+		groups[key] = append(groups[key], secret)
 	}
+
+	sanitizedSecrets := make([]models.SecretModel, 0, len(order))
+	for _, key := range order {
+		sanitizedSecrets = append(sanitizedSecrets, mergeSecretGroup(groups[key]))
+	}
+
+	stdoutMode := utils.RedactionMode(utils.SinkStdout)
 	for _, secret := range sanitizedSecrets {
 		fmt.Printf("Type: %s\n", secret.Type)
-		fmt.Printf("Secret: %s\n", secret.SecretString)
+		fmt.Printf("Secret: %s\n", utils.MaskSecret(secret.SecretString, stdoutMode))
 		fmt.Printf("File Name %s\n", secret.FileLocation)
+		fmt.Printf("Locations: %d\n", len(secret.Locations))
 		fmt.Println()
 		fmt.Println("-----------------------------------")
 	}
 	return sanitizedSecrets
 }
+
+// mergeSecretGroup folds every match of one pattern and secret into a single
+// finding
+func mergeSecretGroup(matches []models.SecretModel) models.SecretModel {
+	canonical := matches[0]
+
+	type position struct {
+		file    string
+		line    int
+		column  int
+		class   string
+		method  string
+		section string
+		offset  uint64
+	}
+	patternsAt := make(map[position][]string)
+	firstMatchAt := make(map[position]models.SecretModel)
+	var positions []position
+	for _, match := range matches {
+		pos := position{match.FileLocation, match.LineNo, match.ColumnNo, match.ClassName, match.MethodName, match.Section, match.Offset}
+		if _, exists := patternsAt[pos]; !exists {
+			positions = append(positions, pos)
+			firstMatchAt[pos] = match
+		}
+		patternsAt[pos] = append(patternsAt[pos], match.Type)
+	}
+
+	sort.Slice(positions, func(i, j int) bool {
+		a, b := positions[i], positions[j]
+		if a.file != b.file {
+			return a.file < b.file
+		}
+		if a.line != b.line {
+			return a.line < b.line
+		}
+		if a.column != b.column {
+			return a.column < b.column
+		}
+		if a.class != b.class {
+			return a.class < b.class
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		if a.section != b.section {
+			return a.section < b.section
+		}
+		return a.offset < b.offset
+	})
+
+	finding := canonical
+	finding.Fingerprint = utils.SecretFingerprint(canonical.Type, canonical.SecretString)
+	finding.Locations = make([]models.SecretLocation, 0, len(positions))
+	for _, pos := range positions {
+		patterns := uniqueStrings(patternsAt[pos])
+		sort.Strings(patterns)
+		match := firstMatchAt[pos]
+		finding.Locations = append(finding.Locations, models.SecretLocation{
+			FileLocation: pos.file,
+			LineNo:       pos.line,
+			ColumnNo:     pos.column,
+			EndLineNo:    match.EndLineNo,
+			EndColumnNo:  match.EndColumnNo,
+			ClassName:    pos.class,
+			MethodName:   pos.method,
+			Section:      pos.section,
+			Offset:       pos.offset,
+			Patterns:     patterns,
+			Context:      match.Context,
+		})
+	}
+
+	// The top level location is the first one, independent of scan order
+	first := firstMatchAt[positions[0]]
+	finding.FileLocation = first.FileLocation
+	finding.LineNo = first.LineNo
+	finding.ColumnNo = first.ColumnNo
+	finding.EndLineNo = first.EndLineNo
+	finding.EndColumnNo = first.EndColumnNo
+	finding.ClassName = first.ClassName
+	finding.MethodName = first.MethodName
+	finding.Section = first.Section
+	finding.Offset = first.Offset
+	finding.Context = first.Context
+
+	return finding
+}
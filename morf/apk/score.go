@@ -0,0 +1,271 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"fmt"
+	"morf/models"
+	"os"
+	"regexp"
+	"strings"
+
+	vip "github.com/spf13/viper"
+)
+
+// Points given to a finding for the confidence of the pattern that found it
+var baseScores = map[string]int{
+	"high":   70,
+	"medium": 50,
+	"low":    30,
+}
+
+// defaultBaseScore is used for confidences outside baseScores
+const defaultBaseScore = 40
+
+// scoreContextLines is how many lines around a finding are read for key names
+// when the scan did not attach context
+const scoreContextLines = 2
+
+var (
+	placeholderSecret = regexp.MustCompile(`(?i)x{4,}|\*{4,}|your[_-]?|example|placeholder|changeme|dummy|<[^>]+>|\$\{`)
+	secretKeyName     = regexp.MustCompile(`(?i)api[_-]?key|secret|token|passw(?:or)?d|pwd|credential|private[_-]?key|access[_-]?key|auth`)
+	decoyKeyName      = regexp.MustCompile(`(?i)example|sample|dummy|placeholder|fake|mock|test`)
+)
+
+// ScoreConfig lists the locations whose findings are downgraded
+type ScoreConfig struct {
+	// TestPaths are lower-cased substrings of paths holding tests, fixtures or samples
+	TestPaths []string
+	// ThirdPartyPackages are package prefixes, in slash form, of bundled SDKs
+	ThirdPartyPackages []string
+}
+
+// DefaultScoreConfig returns the built-in location heuristics
+func DefaultScoreConfig() ScoreConfig {
+	return ScoreConfig{
+		TestPaths: []string{
+			"/test/", "/tests/", "/androidtest/", "/fixtures/", "/mock/", "/mocks/",
+			"/sample/", "/samples/", ".sample", ".example", "sample_config", "example_config",
+		},
+		ThirdPartyPackages: []string{
+			"android/support/", "androidx/", "kotlin/", "kotlinx/", "com/google/", "com/facebook/",
+			"com/squareup/", "okhttp3/", "retrofit2/", "io/reactivex/", "com/amazonaws/",
+			"com/microsoft/", "io/sentry/", "com/crashlytics/", "com/appsflyer/", "com/adjust/",
+			"com/bumptech/", "org/apache/", "org/bouncycastle/",
+		},
+	}
+}
+
+// LoadScoreConfig applies any scoring.* settings from viper on top of the defaults
+func LoadScoreConfig() ScoreConfig {
+	config := DefaultScoreConfig()
+	if vip.IsSet("scoring.test_paths") {
+		config.TestPaths = vip.GetStringSlice("scoring.test_paths")
+	}
+	if vip.IsSet("scoring.third_party_packages") {
+		config.ThirdPartyPackages = vip.GetStringSlice("scoring.third_party_packages")
+	}
+	return config
+}
+
+// ScoreSecrets gives every unscored finding a score from 0 to 100 with the
+// factors it was built from. Findings loaded from older scans are scored
+// without their surrounding source once the decompiled files are gone.
+func ScoreSecrets(findings []models.SecretModel) []models.SecretModel {
+	scorer := newSecretScorer(LoadScoreConfig())
+	for i := range findings {
+		if len(findings[i].ScoreFactors) == 0 {
+			scorer.score(&findings[i])
+		}
+	}
+	return findings
+}
+
+type secretScorer struct {
+	config ScoreConfig
+	files  map[string]*lineIndex // nil when the file could not be read
+}
+
+func newSecretScorer(config ScoreConfig) *secretScorer {
+	return &secretScorer{config: config, files: make(map[string]*lineIndex)}
+}
+
+func (s *secretScorer) score(finding *models.SecretModel) {
+	var factors []models.ScoreFactor
+	add := func(factor string, points int, detail string) {
+		factors = append(factors, models.ScoreFactor{Factor: factor, Points: points, Detail: detail})
+	}
+
+	confidence := strings.ToLower(finding.SecretConfidence)
+	base, ok := baseScores[confidence]
+	if !ok {
+		base = defaultBaseScore
+	}
+	add(models.ScoreFactorBase, base, "pattern confidence "+finding.SecretConfidence)
+
+	entropy := finding.Entropy
+	if entropy == 0 {
+		entropy = ShannonEntropy(finding.SecretString)
+	}
+	switch {
+	case entropy >= 4:
+		add(models.ScoreFactorEntropy, 10, fmt.Sprintf("high entropy, %.2f bits per character", entropy))
+	case entropy < 2.5:
+		add(models.ScoreFactorEntropy, -15, fmt.Sprintf("low entropy, %.2f bits per character", entropy))
+	}
+
+	if placeholder := placeholderSecret.FindString(finding.SecretString); placeholder != "" {
+		add(models.ScoreFactorPlaceholder, -30, fmt.Sprintf("value looks like a placeholder (%q)", placeholder))
+	}
+
+	switch finding.Validation {
+	case models.ValidationValid:
+		add(models.ScoreFactorValidation, 20, "token format is valid: "+finding.ValidationDetail)
+	case models.ValidationInvalid:
+		add(models.ScoreFactorValidation, -30, "token format is invalid: "+finding.ValidationDetail)
+	}
+
+	if finding.Verification != nil {
+		switch finding.Verification.Verified {
+		case models.VerifiedTrue:
+			add(models.ScoreFactorVerification, 30, "accepted by "+finding.Verification.Verifier)
+		case models.VerifiedFalse:
+			add(models.ScoreFactorVerification, -20, "rejected by "+finding.Verification.Verifier)
+		}
+	}
+
+	if points, detail := s.locationFactor(*finding); points != 0 {
+		add(models.ScoreFactorLocation, points, detail)
+	}
+
+	lines := s.contextLines(*finding)
+	if name := secretKeyName.FindString(lines); name != "" {
+		add(models.ScoreFactorContext, 10, fmt.Sprintf("near the name %q", name))
+	}
+	if name := decoyKeyName.FindString(lines); name != "" {
+		add(models.ScoreFactorContext, -10, fmt.Sprintf("near the name %q", name))
+	}
+
+	score := 0
+	for _, factor := range factors {
+		score += factor.Points
+	}
+	finding.Score = max(0, min(100, score))
+	finding.ScoreFactors = factors
+}
+
+// locationFactor downgrades findings all of whose locations are in tests,
+// samples or bundled SDK packages. One location in app code keeps the score.
+func (s *secretScorer) locationFactor(finding models.SecretModel) (int, string) {
+	locations := finding.Locations
+	if len(locations) == 0 {
+		locations = []models.SecretLocation{{FileLocation: finding.FileLocation, ClassName: finding.ClassName}}
+	}
+
+	allTests, allSDK := true, true
+	var testPath, sdkPackage string
+	for _, location := range locations {
+		path := strings.ToLower(strings.ReplaceAll(location.FileLocation, "\\", "/"))
+		if marker := matchingMarker(path, s.config.TestPaths); marker != "" {
+			testPath = marker
+		} else {
+			allTests = false
+		}
+
+		if prefix := matchingPrefix(codePackage(location), s.config.ThirdPartyPackages); prefix != "" {
+			sdkPackage = prefix
+		} else {
+			allSDK = false
+		}
+	}
+
+	switch {
+	case allTests:
+		return -20, fmt.Sprintf("found in test or sample files (%s)", testPath)
+	case allSDK:
+		return -15, fmt.Sprintf("found in third-party SDK package %s", strings.TrimSuffix(sdkPackage, "/"))
+	}
+	return 0, ""
+}
+
+// codePackage returns the slash separated class path of a location, taken from
+// the smali path or the dex class name, or "" for resources
+func codePackage(location models.SecretLocation) string {
+	if location.ClassName != "" {
+		// Dex class names are dotted, such as com.example.Config
+		return strings.ReplaceAll(descriptorToClassName(location.ClassName), ".", "/")
+	}
+	path := strings.ReplaceAll(location.FileLocation, "\\", "/")
+	index := strings.Index(path, "/smali")
+	if index < 0 {
+		return ""
+	}
+	rest := path[index+1:]
+	if slash := strings.Index(rest, "/"); slash >= 0 {
+		return rest[slash+1:]
+	}
+	return ""
+}
+
+func matchingMarker(path string, markers []string) string {
+	for _, marker := range markers {
+		if strings.Contains(path, strings.ToLower(marker)) {
+			return marker
+		}
+	}
+	return ""
+}
+
+func matchingPrefix(pkg string, prefixes []string) string {
+	if pkg == "" {
+		return ""
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(pkg, prefix) {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// contextLines returns the source around a finding with the secret removed,
+// so that only the names next to it are left
+func (s *secretScorer) contextLines(finding models.SecretModel) string {
+	context := finding.Context
+	if context == nil && finding.FileLocation != "" && finding.LineNo > 0 {
+		index, read := s.files[finding.FileLocation]
+		if !read {
+			if content, err := os.ReadFile(finding.FileLocation); err == nil {
+				fileIndex := newLineIndex(content)
+				index = &fileIndex
+			}
+			s.files[finding.FileLocation] = index
+		}
+		if index != nil {
+			context = sourceContext(*index, finding, scoreContextLines)
+		}
+	}
+	if context == nil {
+		return ""
+	}
+
+	text := strings.Join(context.Lines, "\n")
+	if finding.SecretString != "" {
+		text = strings.ReplaceAll(text, finding.SecretString, "")
+	}
+	return text
+}
@@ -0,0 +1,106 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"morf/models"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func scoreOf(finding models.SecretModel) models.SecretModel {
+	newSecretScorer(DefaultScoreConfig()).score(&finding)
+	return finding
+}
+
+func hasFactor(finding models.SecretModel, factor string, points int) bool {
+	for _, f := range finding.ScoreFactors {
+		if f.Factor == factor && f.Points == points {
+			return true
+		}
+	}
+	return false
+}
+
+func TestScoreFactors(t *testing.T) {
+	dir := t.TempDir()
+	appFile := filepath.Join(dir, "smali", "com", "acme", "app", "Config.smali")
+	if err := os.MkdirAll(filepath.Dir(appFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(appFile, []byte(".field apiKey\nconst-string v0, \"q8Zr2LmX4vT9pK1sWd7Yh3Nc\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	app := scoreOf(models.SecretModel{
+		SecretConfidence: "high",
+		SecretString:     "q8Zr2LmX4vT9pK1sWd7Yh3Nc",
+		FileLocation:     appFile,
+		LineNo:           2,
+		Validation:       models.ValidationValid,
+	})
+	if app.Score != 100 {
+		t.Errorf("app secret scored %d, factors %+v", app.Score, app.ScoreFactors)
+	}
+	if !hasFactor(app, models.ScoreFactorContext, 10) {
+		t.Errorf("apiKey field not used, factors %+v", app.ScoreFactors)
+	}
+
+	sdk := scoreOf(models.SecretModel{
+		SecretConfidence: "medium",
+		SecretString:     "q8Zr2LmX4vT9pK1sWd7Yh3Nc",
+		FileLocation:     "classes.dex",
+		ClassName:        descriptorToClassName("Lcom/google/android/gms/Config;"),
+	})
+	if !hasFactor(sdk, models.ScoreFactorLocation, -15) || sdk.Score != 45 {
+		t.Errorf("sdk secret scored %d, factors %+v", sdk.Score, sdk.ScoreFactors)
+	}
+
+	placeholder := scoreOf(models.SecretModel{
+		SecretConfidence: "low",
+		SecretString:     "YOUR_API_KEY",
+		FileLocation:     "/out/source/assets/config.sample.json",
+		Validation:       models.ValidationInvalid,
+	})
+	if placeholder.Score != 0 {
+		t.Errorf("placeholder scored %d, factors %+v", placeholder.Score, placeholder.ScoreFactors)
+	}
+	for _, factor := range []string{models.ScoreFactorPlaceholder, models.ScoreFactorLocation, models.ScoreFactorValidation} {
+		if !hasFactor(placeholder, factor, map[string]int{
+			models.ScoreFactorPlaceholder: -30,
+			models.ScoreFactorLocation:    -20,
+			models.ScoreFactorValidation:  -30,
+		}[factor]) {
+			t.Errorf("missing %s factor in %+v", factor, placeholder.ScoreFactors)
+		}
+	}
+}
+
+func TestScoreSecretsKeepsExistingScores(t *testing.T) {
+	findings := []models.SecretModel{
+		{SecretConfidence: "high", SecretString: "abc", Score: 12, ScoreFactors: []models.ScoreFactor{{Factor: models.ScoreFactorBase, Points: 12}}},
+		{SecretConfidence: "high", SecretString: "q8Zr2LmX4vT9pK1sWd7Yh3Nc"},
+	}
+	ScoreSecrets(findings)
+	if findings[0].Score != 12 {
+		t.Errorf("existing score changed to %d", findings[0].Score)
+	}
+	if findings[1].Score != 80 {
+		t.Errorf("new finding scored %d, factors %+v", findings[1].Score, findings[1].ScoreFactors)
+	}
+}
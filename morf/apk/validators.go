@@ -0,0 +1,276 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"morf/models"
+	"regexp"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Confidence given to findings that pass or fail their validator
+const (
+	validatedConfidence   = "high"
+	invalidatedConfidence = "low"
+)
+
+// SecretValidator checks the structure of a secret without any network
+// access. It returns models.ValidationValid or models.ValidationInvalid with a
+// short reason, or an empty result when the secret is not of a shape it knows.
+type SecretValidator func(secret string) (result string, detail string)
+
+var (
+	validatorsMu sync.RWMutex
+
+	// secretValidators holds the validators by name, as used in the
+	// validator field of a pattern
+	secretValidators = map[string]SecretValidator{
+		"github":            validateGitHubToken,
+		"slack":             validateSlackToken,
+		"stripe":            validateStripeKey,
+		"aws_access_key_id": validateAWSAccessKeyID,
+		"luhn":              validateLuhn,
+	}
+
+	// patternValidators picks a validator for patterns without a validator
+	// field, keyed by lower-cased pattern name as used in the shipped files
+	patternValidators = map[string]string{
+		"github token":              "github",
+		"slack token":               "slack",
+		"slack webhook":             "slack",
+		"stripe api key":            "stripe",
+		"stripe restricted api key": "stripe",
+		"aws api key":               "aws_access_key_id",
+		"aws access key":            "aws_access_key_id",
+		"credit card":               "luhn",
+	}
+)
+
+// RegisterValidator adds or replaces a named validator
+func RegisterValidator(name string, validator SecretValidator) {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+	secretValidators[name] = validator
+}
+
+// RegisterPatternValidator makes patterns with the given name use a validator
+// unless their validator field names another one
+func RegisterPatternValidator(patternName string, validatorName string) {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+	patternValidators[strings.ToLower(patternName)] = validatorName
+}
+
+// lookupValidator returns the validator for a pattern, preferring its
+// validator field over its name
+func lookupValidator(definition PatternDefinition) (SecretValidator, error) {
+	validatorsMu.RLock()
+	defer validatorsMu.RUnlock()
+
+	name := definition.Validator
+	if name == "" {
+		name = patternValidators[strings.ToLower(definition.Name)]
+	}
+	if name == "" {
+		return nil, nil
+	}
+
+	validator, ok := secretValidators[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown validator %q", name)
+	}
+	return validator, nil
+}
+
+// applyValidation runs a validator on a finding and raises or lowers its confidence
+func applyValidation(finding *models.SecretModel, validator SecretValidator) {
+	if validator == nil {
+		return
+	}
+
+	result, detail := validator(finding.SecretString)
+	switch result {
+	case models.ValidationValid:
+		finding.SecretConfidence = validatedConfidence
+	case models.ValidationInvalid:
+		finding.SecretConfidence = invalidatedConfidence
+		log.Debugf("%s in %s:%d failed validation: %s", finding.Type, finding.FileLocation, finding.LineNo, detail)
+	default:
+		return
+	}
+	finding.Validation = result
+	finding.ValidationDetail = detail
+}
+
+var (
+	gitHubTokenRegex = regexp.MustCompile(`^gh[pousr]_([A-Za-z0-9]{30})([A-Za-z0-9]{6})$`)
+
+	slackBotTokenRegex  = regexp.MustCompile(`^xoxb-\d{8,13}-\d{8,13}-[A-Za-z0-9]{24}$`)
+	slackUserTokenRegex = regexp.MustCompile(`^xoxp-\d{8,13}-\d{8,13}-\d{8,13}-[a-f0-9]{32}$`)
+	slackAppTokenRegex  = regexp.MustCompile(`^xapp-\d-[A-Z0-9]{9,11}-\d{8,13}-[a-f0-9]{64}$`)
+	slackWebhookRegex   = regexp.MustCompile(`^https://hooks\.slack\.com/services/T[A-Z0-9]{8,10}/B[A-Z0-9]{8,10}/[A-Za-z0-9]{24}$`)
+
+	stripeKeyRegex = regexp.MustCompile(`^(?:sk|pk|rk)_(?:live|test)_[A-Za-z0-9]{24,247}$`)
+
+	awsAccessKeyIDRegex = regexp.MustCompile(`^(?:AKIA|ASIA|ABIA|ACCA|AGPA|AIDA|AIPA|ANPA|ANVA|AROA|APKA|ASCA|A3T[A-Z0-9])[A-Z2-7]{16}$`)
+)
+
+// base62Alphabet is the digit order of the GitHub token checksum
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// validateGitHubToken checks the CRC32 checksum in the last six characters of
+// a ghp_, gho_, ghu_, ghs_ or ghr_ token
+func validateGitHubToken(secret string) (string, string) {
+	if !strings.HasPrefix(secret, "gh") || len(secret) < 4 || secret[3] != '_' {
+		return "", ""
+	}
+	match := gitHubTokenRegex.FindStringSubmatch(secret)
+	if match == nil {
+		return models.ValidationInvalid, "not 36 base62 characters after the prefix"
+	}
+	if gitHubChecksum(match[1]) != match[2] {
+		return models.ValidationInvalid, "checksum mismatch"
+	}
+	return models.ValidationValid, "checksum matches"
+}
+
+// gitHubChecksum encodes the CRC32 of the random part of a token as six base62 digits
+func gitHubChecksum(random string) string {
+	checksum := crc32.ChecksumIEEE([]byte(random))
+	encoded := make([]byte, 6)
+	for i := len(encoded) - 1; i >= 0; i-- {
+		encoded[i] = base62Alphabet[checksum%62]
+		checksum /= 62
+	}
+	return string(encoded)
+}
+
+// validateSlackToken checks the segments of bot, user and app tokens and of
+// incoming webhook URLs
+func validateSlackToken(secret string) (string, string) {
+	var regex *regexp.Regexp
+	switch {
+	case strings.HasPrefix(secret, "xoxb-"):
+		regex = slackBotTokenRegex
+	case strings.HasPrefix(secret, "xoxp-"):
+		regex = slackUserTokenRegex
+	case strings.HasPrefix(secret, "xapp-"):
+		regex = slackAppTokenRegex
+	case strings.HasPrefix(secret, "https://hooks.slack.com/"):
+		regex = slackWebhookRegex
+	default:
+		return "", ""
+	}
+
+	if !regex.MatchString(secret) {
+		return models.ValidationInvalid, "unexpected segment structure"
+	}
+	return models.ValidationValid, "segment structure matches"
+}
+
+// validateStripeKey checks the prefix and length of secret, publishable and restricted keys
+func validateStripeKey(secret string) (string, string) {
+	if !strings.HasPrefix(secret, "sk_") && !strings.HasPrefix(secret, "pk_") && !strings.HasPrefix(secret, "rk_") {
+		return "", ""
+	}
+	if !stripeKeyRegex.MatchString(secret) {
+		return models.ValidationInvalid, "unexpected prefix or length"
+	}
+	return models.ValidationValid, "prefix and length match"
+}
+
+// maxAWSAccountID is the largest 12-digit AWS account ID
+const maxAWSAccountID = 999999999999
+
+// validateAWSAccessKeyID checks the prefix and base32 body of an access key ID
+// and decodes the account it belongs to
+func validateAWSAccessKeyID(secret string) (string, string) {
+	if len(secret) != 20 || !strings.HasPrefix(secret, "A") {
+		return "", ""
+	}
+	if !awsAccessKeyIDRegex.MatchString(secret) {
+		return models.ValidationInvalid, "unexpected prefix or characters"
+	}
+	if strings.Contains(secret, "EXAMPLE") {
+		return models.ValidationInvalid, "documentation example"
+	}
+
+	account, err := awsAccountID(secret)
+	if err != nil {
+		return models.ValidationInvalid, err.Error()
+	}
+	if account == 0 || account > maxAWSAccountID {
+		return models.ValidationInvalid, "encodes no valid account"
+	}
+	return models.ValidationValid, fmt.Sprintf("account %012d", account)
+}
+
+// awsAccountID decodes the account ID packed into bits 7 to 46 of the base32
+// body of newer access key IDs
+func awsAccountID(keyID string) (uint64, error) {
+	decoded, err := base32.StdEncoding.DecodeString(keyID[4:])
+	if err != nil {
+		return 0, fmt.Errorf("invalid base32 body")
+	}
+
+	var buf [8]byte
+	copy(buf[2:], decoded[:6])
+	return (binary.BigEndian.Uint64(buf[:]) & 0x7fffffffff80) >> 7, nil
+}
+
+// validateLuhn checks the length and Luhn checksum of a card number, ignoring
+// spaces and dashes
+func validateLuhn(secret string) (string, string) {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(secret)
+	if digits == "" {
+		return "", ""
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return "", ""
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return models.ValidationInvalid, "not 13 to 19 digits"
+	}
+	if strings.Count(digits, digits[:1]) == len(digits) {
+		return models.ValidationInvalid, "repeated digit"
+	}
+
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		digit := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	if sum%10 != 0 {
+		return models.ValidationInvalid, "Luhn checksum fails"
+	}
+	return models.ValidationValid, "Luhn checksum passes"
+}
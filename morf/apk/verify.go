@@ -0,0 +1,345 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"morf/models"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	vip "github.com/spf13/viper"
+)
+
+// maxVerifyResponseSize caps how much of a provider response is read
+const maxVerifyResponseSize = 1 << 20
+
+// Verifier checks whether a secret is live by calling the cheapest read-only
+// endpoint of its provider
+type Verifier interface {
+	// DefaultBaseURL is the API called unless verify.base_urls.<name> overrides it
+	DefaultBaseURL() string
+	// Verify calls the provider at baseURL. Responses that do not tell whether
+	// the secret is live give models.VerifiedUnknown.
+	Verify(ctx context.Context, client *http.Client, baseURL string, secret string) models.SecretVerification
+}
+
+var (
+	verifiersMu sync.RWMutex
+
+	// secretVerifiers holds the verifiers by name
+	secretVerifiers = map[string]Verifier{
+		"github": gitHubVerifier{},
+		"slack":  slackVerifier{},
+		"stripe": stripeVerifier{},
+	}
+
+	// typeVerifiers picks the verifier for a finding, keyed by lower-cased secret type
+	typeVerifiers = map[string]string{
+		"github token":              "github",
+		"slack token":               "slack",
+		"stripe api key":            "stripe",
+		"stripe restricted api key": "stripe",
+	}
+)
+
+// RegisterVerifier adds or replaces a named verifier
+func RegisterVerifier(name string, verifier Verifier) {
+	verifiersMu.Lock()
+	defer verifiersMu.Unlock()
+	secretVerifiers[name] = verifier
+}
+
+// RegisterTypeVerifier makes findings of a secret type use a named verifier
+func RegisterTypeVerifier(secretType string, verifierName string) {
+	verifiersMu.Lock()
+	defer verifiersMu.Unlock()
+	typeVerifiers[strings.ToLower(secretType)] = verifierName
+}
+
+// lookupVerifier returns the name and verifier for a secret type
+func lookupVerifier(secretType string) (string, Verifier) {
+	verifiersMu.RLock()
+	defer verifiersMu.RUnlock()
+
+	name := typeVerifiers[strings.ToLower(secretType)]
+	return name, secretVerifiers[name]
+}
+
+// VerifyConfig controls live verification, which is off unless enabled
+type VerifyConfig struct {
+	Enabled bool
+	// RateLimit is the most requests sent per second, across all providers
+	RateLimit float64
+	Timeout   time.Duration
+	// BaseURLs replaces the provider APIs by verifier name, e.g. with a local stand-in
+	BaseURLs map[string]string
+}
+
+// DefaultVerifyConfig returns the built-in verification settings
+func DefaultVerifyConfig() VerifyConfig {
+	return VerifyConfig{
+		Enabled:   false,
+		RateLimit: 2,
+		Timeout:   10 * time.Second,
+		BaseURLs:  map[string]string{},
+	}
+}
+
+// LoadVerifyConfig applies any verify.* settings from viper on top of the defaults
+func LoadVerifyConfig() VerifyConfig {
+	config := DefaultVerifyConfig()
+	if vip.IsSet("verify.enabled") {
+		config.Enabled = vip.GetBool("verify.enabled")
+	}
+	if vip.IsSet("verify.rate_limit") {
+		config.RateLimit = vip.GetFloat64("verify.rate_limit")
+	}
+	if vip.IsSet("verify.timeout") {
+		config.Timeout = vip.GetDuration("verify.timeout")
+	}
+
+	// Looked up per verifier so MORF_VERIFY_BASE_URLS_<NAME> works as well
+	verifiersMu.RLock()
+	defer verifiersMu.RUnlock()
+	for name := range secretVerifiers {
+		if key := "verify.base_urls." + name; vip.IsSet(key) {
+			config.BaseURLs[name] = vip.GetString(key)
+		}
+	}
+	return config
+}
+
+// SecretVerifier verifies findings one request at a time, no faster than the
+// configured rate. Each secret is only sent once per verifier.
+type SecretVerifier struct {
+	config   VerifyConfig
+	client   *http.Client
+	interval time.Duration
+	next     time.Time
+	cache    map[string]models.SecretVerification
+}
+
+// NewSecretVerifier creates a verifier for the given settings
+func NewSecretVerifier(config VerifyConfig) *SecretVerifier {
+	verifier := &SecretVerifier{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		cache:  make(map[string]models.SecretVerification),
+	}
+	if config.RateLimit > 0 {
+		verifier.interval = time.Duration(float64(time.Second) / config.RateLimit)
+	}
+	return verifier
+}
+
+// VerifySecrets records on each finding whether its provider accepts the
+// secret. Suppressed findings and those that failed offline validation are
+// never sent. It does nothing unless verify.enabled is set.
+func VerifySecrets(findings []models.SecretModel) []models.SecretModel {
+	config := LoadVerifyConfig()
+	if !config.Enabled {
+		return findings
+	}
+	return NewSecretVerifier(config).Verify(context.Background(), findings)
+}
+
+// Verify sets the verification of every finding that has a verifier
+func (v *SecretVerifier) Verify(ctx context.Context, findings []models.SecretModel) []models.SecretModel {
+	for i := range findings {
+		finding := &findings[i]
+		if finding.Suppressed || finding.Validation == models.ValidationInvalid || finding.SecretString == "" {
+			continue
+		}
+		name, verifier := lookupVerifier(finding.Type)
+		if verifier == nil {
+			continue
+		}
+
+		key := name + "\x00" + finding.SecretString
+		result, ok := v.cache[key]
+		if !ok {
+			if err := v.wait(ctx); err != nil {
+				return findings
+			}
+			baseURL := v.config.BaseURLs[name]
+			if baseURL == "" {
+				baseURL = verifier.DefaultBaseURL()
+			}
+			result = verifier.Verify(ctx, v.client, strings.TrimRight(baseURL, "/"), finding.SecretString)
+			result.Verifier = name
+			result.CheckedAt = time.Now().UTC()
+			v.cache[key] = result
+			log.Infof("Verified %s in %s: %s", finding.Type, finding.FileLocation, result.Verified)
+		}
+		finding.Verification = &result
+	}
+	return findings
+}
+
+// wait blocks until the rate limit allows another request
+func (v *SecretVerifier) wait(ctx context.Context) error {
+	now := time.Now()
+	if v.next.After(now) {
+		timer := time.NewTimer(v.next.Sub(now))
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+		now = v.next
+	}
+	v.next = now.Add(v.interval)
+	return nil
+}
+
+// verifyRequest sends a request and decodes a JSON body into out when given.
+// A transport error is reported as an unknown result.
+func verifyRequest(client *http.Client, req *http.Request, out interface{}) (models.SecretVerification, *http.Response) {
+	result := models.SecretVerification{Verified: models.VerifiedUnknown}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Metadata = map[string]string{"error": err.Error()}
+		return result, nil
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxVerifyResponseSize))
+	if err == nil && out != nil && len(body) > 0 {
+		if err := json.Unmarshal(body, out); err != nil {
+			log.Debugf("Unable to decode response from %s: %v", req.URL.Host, err)
+		}
+	}
+	return result, resp
+}
+
+// gitHubVerifier calls GET /user, which any token may read
+type gitHubVerifier struct{}
+
+func (gitHubVerifier) DefaultBaseURL() string { return "https://api.github.com" }
+
+func (gitHubVerifier) Verify(ctx context.Context, client *http.Client, baseURL string, secret string) models.SecretVerification {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/user", nil)
+	if err != nil {
+		return models.SecretVerification{Verified: models.VerifiedUnknown}
+	}
+	req.Header.Set("Authorization", "token "+secret)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	var user struct {
+		Login string `json:"login"`
+		Type  string `json:"type"`
+	}
+	result, resp := verifyRequest(client, req, &user)
+	if resp == nil {
+		return result
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		result.Verified = models.VerifiedTrue
+		result.Metadata = map[string]string{"login": user.Login, "type": user.Type, "scopes": resp.Header.Get("X-OAuth-Scopes")}
+	case http.StatusUnauthorized:
+		result.Verified = models.VerifiedFalse
+	}
+	return result
+}
+
+// slackVerifier calls auth.test, which reports the workspace of any token
+type slackVerifier struct{}
+
+func (slackVerifier) DefaultBaseURL() string { return "https://slack.com/api" }
+
+// slackInvalidErrors are the auth.test errors meaning the token is dead
+var slackInvalidErrors = map[string]bool{
+	"invalid_auth": true, "not_authed": true, "account_inactive": true, "token_revoked": true, "token_expired": true,
+}
+
+func (slackVerifier) Verify(ctx context.Context, client *http.Client, baseURL string, secret string) models.SecretVerification {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/auth.test", nil)
+	if err != nil {
+		return models.SecretVerification{Verified: models.VerifiedUnknown}
+	}
+	req.Header.Set("Authorization", "Bearer "+secret)
+
+	var auth struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+		Team  string `json:"team"`
+		User  string `json:"user"`
+		URL   string `json:"url"`
+	}
+	result, resp := verifyRequest(client, req, &auth)
+	if resp == nil || resp.StatusCode != http.StatusOK {
+		return result
+	}
+
+	switch {
+	case auth.OK:
+		result.Verified = models.VerifiedTrue
+		result.Metadata = map[string]string{"team": auth.Team, "user": auth.User, "url": auth.URL}
+	case slackInvalidErrors[auth.Error]:
+		result.Verified = models.VerifiedFalse
+		result.Metadata = map[string]string{"error": auth.Error}
+	default:
+		result.Metadata = map[string]string{"error": auth.Error}
+	}
+	return result
+}
+
+// stripeVerifier calls GET /v1/balance. Restricted keys without balance
+// access are refused with 403, which still proves the key exists.
+type stripeVerifier struct{}
+
+func (stripeVerifier) DefaultBaseURL() string { return "https://api.stripe.com" }
+
+func (stripeVerifier) Verify(ctx context.Context, client *http.Client, baseURL string, secret string) models.SecretVerification {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/v1/balance", nil)
+	if err != nil {
+		return models.SecretVerification{Verified: models.VerifiedUnknown}
+	}
+	req.SetBasicAuth(secret, "")
+
+	var balance struct {
+		LiveMode bool `json:"livemode"`
+	}
+	result, resp := verifyRequest(client, req, &balance)
+	if resp == nil {
+		return result
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		result.Verified = models.VerifiedTrue
+		result.Metadata = map[string]string{"livemode": fmt.Sprint(balance.LiveMode)}
+	case http.StatusForbidden:
+		result.Verified = models.VerifiedTrue
+		result.Metadata = map[string]string{"restricted": "true"}
+	case http.StatusUnauthorized:
+		result.Verified = models.VerifiedFalse
+	}
+	return result
+}
@@ -0,0 +1,141 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apk
+
+import (
+	"context"
+	"encoding/json"
+	"morf/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newProviderStandIn answers like GitHub, Slack and Stripe, accepting only the
+// secrets prefixed with live
+func newProviderStandIn(t *testing.T, requests *int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		switch r.URL.Path {
+		case "/github/user":
+			if r.Header.Get("Authorization") != "token live-github" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("X-OAuth-Scopes", "repo")
+			json.NewEncoder(w).Encode(map[string]string{"login": "octocat", "type": "User"})
+		case "/slack/auth.test":
+			if r.Header.Get("Authorization") != "Bearer live-slack" {
+				json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error": "invalid_auth"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "team": "Example", "user": "bot"})
+		case "/stripe/v1/balance":
+			if user, _, _ := r.BasicAuth(); user != "live-stripe" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]bool{"livemode": true})
+		default:
+			w.WriteHeader(http.StatusTeapot)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSecretVerifier(t *testing.T) {
+	requests := 0
+	server := newProviderStandIn(t, &requests)
+
+	config := DefaultVerifyConfig()
+	config.Enabled = true
+	config.RateLimit = 0
+	config.BaseURLs = map[string]string{
+		"github": server.URL + "/github",
+		"slack":  server.URL + "/slack",
+		"stripe": server.URL + "/stripe",
+	}
+
+	findings := []models.SecretModel{
+		{Type: "Github Token", SecretString: "live-github"},
+		{Type: "Github Token", SecretString: "live-github", FileLocation: "Other.smali"},
+		{Type: "Slack Token", SecretString: "dead-slack"},
+		{Type: "Stripe API Key", SecretString: "live-stripe"},
+		{Type: "Stripe API Key", SecretString: "suppressed", Suppressed: true},
+		{Type: "Github Token", SecretString: "invalid", Validation: models.ValidationInvalid},
+		{Type: "Google API Key", SecretString: "no-verifier"},
+	}
+	NewSecretVerifier(config).Verify(context.Background(), findings)
+
+	want := []string{models.VerifiedTrue, models.VerifiedTrue, models.VerifiedFalse, models.VerifiedTrue, "", "", ""}
+	for i, verified := range want {
+		got := ""
+		if findings[i].Verification != nil {
+			got = findings[i].Verification.Verified
+		}
+		if got != verified {
+			t.Errorf("finding %d verified = %q, want %q", i, got, verified)
+		}
+	}
+	if requests != 3 {
+		t.Errorf("sent %d requests, want 3", requests)
+	}
+
+	github := findings[0].Verification
+	if github.Verifier != "github" || github.StatusCode != http.StatusOK || github.Metadata["login"] != "octocat" || github.Metadata["scopes"] != "repo" {
+		t.Errorf("github verification = %+v", github)
+	}
+	if findings[2].Verification.Metadata["error"] != "invalid_auth" {
+		t.Errorf("slack verification = %+v", findings[2].Verification)
+	}
+}
+
+func TestSecretVerifierUnknownAndRateLimit(t *testing.T) {
+	requests := 0
+	server := newProviderStandIn(t, &requests)
+
+	config := DefaultVerifyConfig()
+	config.RateLimit = 20
+	config.BaseURLs = map[string]string{"github": server.URL + "/unexpected"}
+
+	findings := []models.SecretModel{
+		{Type: "Github Token", SecretString: "a"},
+		{Type: "Github Token", SecretString: "b"},
+		{Type: "Github Token", SecretString: "c"},
+	}
+
+	start := time.Now()
+	NewSecretVerifier(config).Verify(context.Background(), findings)
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("three requests at 20/s took %v, want at least 100ms", elapsed)
+	}
+	for i, finding := range findings {
+		if finding.Verification == nil || finding.Verification.Verified != models.VerifiedUnknown || finding.Verification.StatusCode != http.StatusTeapot {
+			t.Errorf("finding %d verification = %+v, want unknown", i, finding.Verification)
+		}
+	}
+}
+
+func TestVerifySecretsIsOptIn(t *testing.T) {
+	findings := []models.SecretModel{{Type: "Github Token", SecretString: "live-github"}}
+	if VerifySecrets(findings)[0].Verification != nil {
+		t.Error("secrets were verified without verify.enabled")
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"morf/models"
+	"morf/utils"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	vip "github.com/spf13/viper"
+)
+
+// GetBaselineCmd returns the command for managing the suppression baseline
+func GetBaselineCmd() *cobra.Command {
+	baselineCmd := &cobra.Command{
+		Use:   "baseline",
+		Short: "Manage the suppression baseline",
+		Long:  `Accept known findings so that rescans and integrations only report new ones`,
+	}
+
+	baselineCmd.AddCommand(getBaselineCreateCmd())
+	return baselineCmd
+}
+
+func getBaselineCreateCmd() *cobra.Command {
+	var outPath string
+	var packageName string
+	var justification string
+	var expires string
+	var global bool
+
+	createCmd := &cobra.Command{
+		Use:   "create <report.json>",
+		Short: "Snapshot the findings of a scan report into the baseline file",
+		Long: `Reads a MORF report (the full report or the Secrets_ report) and adds a
+suppression for every finding that is not already in the baseline file`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fs := utils.GetAppFS()
+
+			reportPackage, findings, err := readReport(utils.ReadFile(fs, args[0]))
+			if err != nil {
+				return err
+			}
+			if packageName == "" {
+				packageName = reportPackage
+			}
+			if global {
+				packageName = ""
+			}
+
+			expiresAt, err := parseExpiry(expires, time.Now())
+			if err != nil {
+				return err
+			}
+
+			if outPath == "" {
+				outPath = vip.GetString("baseline_path")
+			}
+			baseline, err := utils.LoadBaseline(fs, outPath)
+			if err != nil {
+				return err
+			}
+			if baseline.Version == 0 {
+				baseline.Version = utils.BaselineVersion
+				baseline.CreatedAt = time.Now().UTC()
+			}
+
+			added := utils.AddToBaseline(&baseline, packageName, findings, justification, expiresAt)
+			if err := utils.SaveBaseline(fs, outPath, baseline); err != nil {
+				return err
+			}
+
+			log.Infof("Added %d of %d findings to %s (%d suppressions)", added, len(findings), outPath, len(baseline.Suppressions))
+			return nil
+		},
+	}
+
+	createCmd.Flags().StringVarP(&outPath, "out", "o", "", "Baseline file to create or extend (default: baseline_path setting)")
+	createCmd.Flags().StringVarP(&packageName, "package", "p", "", "Package the suppressions apply to (default: package in the report)")
+	createCmd.Flags().BoolVar(&global, "global", false, "Apply the suppressions to every package")
+	createCmd.Flags().StringVarP(&justification, "justification", "j", "", "Reason the findings are accepted")
+	createCmd.Flags().StringVarP(&expires, "expires", "e", "", "Expiry as a date (2006-01-02) or a duration from now (e.g. 720h)")
+
+	return createCmd
+}
+
+// readReport extracts the package name and findings from a full or secrets-only report
+func readReport(data []byte) (string, []models.SecretModel, error) {
+	if len(data) == 0 {
+		return "", nil, fmt.Errorf("report is empty or could not be read")
+	}
+
+	var findings []models.SecretModel
+	if err := json.Unmarshal(data, &findings); err == nil {
+		return "", findings, nil
+	}
+
+	var report models.Secrets
+	if err := json.Unmarshal(data, &report); err != nil {
+		return "", nil, fmt.Errorf("failed to parse report: %v", err)
+	}
+	return report.PackageDataModel.PackageName, report.SecretModel, nil
+}
+
+// parseExpiry accepts a date or a duration relative to now. An empty value never expires.
+func parseExpiry(value string, now time.Time) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return &date, nil
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		expiresAt := now.Add(duration).UTC()
+		return &expiresAt, nil
+	}
+	return nil, fmt.Errorf("invalid expiry %q: use a date (2006-01-02) or a duration (e.g. 720h)", value)
+}
@@ -22,6 +22,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	vip "github.com/spf13/viper"
 )
 
 // GetCliCmd returns the CLI command for MORF
@@ -29,6 +30,9 @@ func GetCliCmd() *cobra.Command {
 	var apkPath string
 	var jsonPath string
 	var useDb bool
+	var baselinePath string
+	var secretsOnly bool
+	var verify bool
 
 	var cliCmd = &cobra.Command{
 		Use:   "cli",
@@ -51,6 +55,20 @@ func GetCliCmd() *cobra.Command {
 				return
 			}
 
+			if baselinePath != "" {
+				vip.Set("baseline_path", baselinePath)
+			}
+
+			if verify {
+				vip.Set("verify.enabled", true)
+			}
+
+			if secretsOnly {
+				log.Info("Starting secrets-only scan for:", apkPath)
+				apk.StartCliSecretsScan(apkPath)
+				return
+			}
+
 			// Initialize database if requested
 			if useDb {
 				db.InitDB()
@@ -71,6 +89,9 @@ func GetCliCmd() *cobra.Command {
 	cliCmd.Flags().StringVarP(&apkPath, "apk", "a", "", "Path to the APK file")
 	cliCmd.Flags().StringVarP(&jsonPath, "json", "j", "", "Path to output JSON file")
 	cliCmd.Flags().BoolVarP(&useDb, "use-db", "d", false, "Enable database storage")
+	cliCmd.Flags().StringVarP(&baselinePath, "baseline", "b", "", "Path to the suppression baseline file")
+	cliCmd.Flags().BoolVar(&verify, "verify", false, "Check whether found secrets are live by calling their providers")
+	cliCmd.Flags().BoolVarP(&secretsOnly, "secrets-only", "s", false, "Scan the dex string tables for secrets without decompiling or collecting metadata")
 
 	return cliCmd
 }
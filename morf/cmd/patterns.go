@@ -0,0 +1,151 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"morf/apk"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// defaultPatternsDir is where the pattern files are shipped in the Docker image
+const defaultPatternsDir = "/app/patterns"
+
+// GetPatternsCmd returns the command for working with secret pattern files
+func GetPatternsCmd() *cobra.Command {
+	patternsCmd := &cobra.Command{
+		Use:   "patterns",
+		Short: "Work with secret pattern files",
+	}
+
+	patternsCmd.AddCommand(getPatternsTestCmd())
+	patternsCmd.AddCommand(getPatternsImportCmd())
+	return patternsCmd
+}
+
+func getPatternsTestCmd() *cobra.Command {
+	var slowThreshold time.Duration
+	var noiseThreshold int
+	var verbose bool
+
+	testCmd := &cobra.Command{
+		Use:   "test [dir]",
+		Short: "Compile every pattern and check its examples and counter examples",
+		Long: `Compiles every regex in the YAML files of dir (default /app/patterns), checks
+that each pattern reports its examples and none of its counter_examples, and
+times each regex on generated text to spot slow or noisy patterns.
+Exits non-zero when any pattern fails.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := defaultPatternsDir
+			if len(args) > 0 {
+				dir = args[0]
+			}
+
+			report := apk.RunPatternTests(dir, apk.PatternTestOptions{
+				SlowThreshold:       slowThreshold,
+				NoiseMatchThreshold: noiseThreshold,
+			})
+			if report.Files == 0 {
+				return fmt.Errorf("no pattern files found in %s", dir)
+			}
+
+			out := cmd.OutOrStdout()
+			failures, outliers := 0, 0
+			for _, result := range report.Results {
+				for _, failure := range result.Failures {
+					fmt.Fprintf(out, "FAIL %s: %s: %s\n", result.File, result.Pattern, failure)
+					failures++
+				}
+				for _, outlier := range result.Outliers {
+					fmt.Fprintf(out, "WARN %s: %s: %s\n", result.File, result.Pattern, outlier)
+					outliers++
+				}
+				if verbose && len(result.Failures) == 0 {
+					fmt.Fprintf(out, "ok   %s: %s (%d samples, %v)\n", result.File, result.Pattern, result.Samples, result.Duration.Round(time.Microsecond))
+				}
+			}
+
+			fmt.Fprintf(out, "%d patterns in %d files, %d samples, %d patterns without samples, %d failures, %d outliers\n",
+				report.Patterns, report.Files, report.Samples, report.Untested, failures, outliers)
+
+			if report.Failed() {
+				return fmt.Errorf("%d pattern test failures", failures)
+			}
+			return nil
+		},
+	}
+
+	testCmd.Flags().DurationVar(&slowThreshold, "slow", apk.DefaultSlowPatternThreshold, "Flag patterns taking longer than this to scan the generated text")
+	testCmd.Flags().IntVar(&noiseThreshold, "noise", apk.DefaultNoiseMatchThreshold, "Flag patterns matching the generated text more often than this")
+	testCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Also list passing patterns")
+	return testCmd
+}
+
+func getPatternsImportCmd() *cobra.Command {
+	var from, output, confidence string
+
+	importCmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Convert another scanner's rule file into a pattern file",
+		Long: `Converts the rules of another secret scanner into MORF's pattern YAML.
+Regexes, keywords, secretGroup, entropy and secret allowlists are kept; rules
+or parts of rules without an equivalent are listed on stderr.
+Supported formats: ` + strings.Join(apk.PatternImportFormats(), ", "),
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+
+			patterns, issues, err := apk.ImportPatterns(from, data, apk.ImportOptions{Confidence: confidence})
+			if err != nil {
+				return err
+			}
+
+			skipped := 0
+			for _, issue := range issues {
+				fmt.Fprintln(cmd.ErrOrStderr(), issue)
+				if issue.Skipped {
+					skipped++
+				}
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "%d rules imported, %d skipped, %d issues\n", len(patterns.Patterns), skipped, len(issues))
+
+			yamlData, err := yaml.Marshal(patterns)
+			if err != nil {
+				return err
+			}
+			if output == "" {
+				_, err = cmd.OutOrStdout().Write(yamlData)
+				return err
+			}
+			return os.WriteFile(output, yamlData, 0644)
+		},
+	}
+
+	importCmd.Flags().StringVar(&from, "from", "gitleaks", "Format of the rule file")
+	importCmd.Flags().StringVarP(&output, "output", "o", "", "Write the pattern file here instead of stdout")
+	importCmd.Flags().StringVar(&confidence, "confidence", "medium", "Confidence given to the imported patterns")
+	return importCmd
+}
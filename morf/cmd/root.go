@@ -19,6 +19,7 @@ package cmd
 
 import (
 	"os"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 	cob "github.com/spf13/cobra"
@@ -72,7 +73,18 @@ func runMORF(cmd *cob.Command, args []string) error {
 func init() {
 	vip.SetDefault("port", 8080)
 	vip.SetDefault("backup_path", "backup/")
+	vip.SetDefault("baseline_path", "morf-baseline.json")
+	vip.SetDefault("context_lines", 3)
+	vip.SetDefault("redaction.default", "partial")
+	vip.SetDefault("redaction.partial_prefix", 4)
+	vip.SetDefault("redaction.partial_suffix", 2)
+
+	// Any setting can be overridden from the environment, e.g. MORF_ENTROPY_HEX_THRESHOLD=3.5
+	vip.SetEnvPrefix("morf")
+	vip.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	vip.AutomaticEnv()
 
 	MorfCmd.AddCommand(GetCliCmd())
 	MorfCmd.AddCommand(GetServerCmd())
+	MorfCmd.AddCommand(GetBaselineCmd())
 }
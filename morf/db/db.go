@@ -157,7 +157,7 @@ func runMigrations() error {
 	}
 
 	// Run auto migrations first
-	if err := GormDB.AutoMigrate(&models.Secrets{}); err != nil {
+	if err := GormDB.AutoMigrate(&models.Secrets{}, &models.Suppression{}, &models.SecretPattern{}); err != nil {
 		// If migration fails, check if it's a JSON error
 		if strings.Contains(err.Error(), "Invalid JSON text") {
 			log.Warn("Migration failed due to JSON error, attempting to continue with database operations")
@@ -319,3 +319,135 @@ func GetLastSecret(db interface{}) models.Secrets {
 
 	return secret
 }
+
+// GetEndpointInventory retrieves the endpoints of every scanned APK, or of
+// one package when packageName is set. Only the columns needed to search the
+// endpoints are loaded.
+func GetEndpointInventory(packageName string) []models.Secrets {
+	var secrets []models.Secrets
+
+	if GormDB == nil || !DatabaseRequired {
+		return secrets
+	}
+
+	query := GormDB.Select("id", "file_name", "package_name", "version_name", "endpoints")
+	if packageName != "" {
+		query = query.Where("package_name = ?", packageName)
+	}
+	if result := query.Find(&secrets); result.Error != nil {
+		log.Error("Failed to get endpoints:", result.Error)
+	}
+
+	return secrets
+}
+
+// GetSuppressions retrieves the suppressions that apply to a package, including
+// those stored without a package name. An empty package name returns all of them.
+func GetSuppressions(packageName string) []models.Suppression {
+	var suppressions []models.Suppression
+
+	if GormDB == nil || !DatabaseRequired {
+		return suppressions
+	}
+
+	query := GormDB.Order("id")
+	if packageName != "" {
+		query = query.Where("package_name = ? OR package_name = ''", packageName)
+	}
+	if result := query.Find(&suppressions); result.Error != nil {
+		log.Error("Failed to get suppressions:", result.Error)
+	}
+
+	return suppressions
+}
+
+// InsertSuppression stores a suppression in the database
+func InsertSuppression(suppression *models.Suppression) error {
+	if GormDB == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	log.Infof("Suppressing finding %s for package %q", suppression.Fingerprint, suppression.PackageName)
+	return GormDB.Create(suppression).Error
+}
+
+// DeleteSuppression removes a suppression from the database
+func DeleteSuppression(id uint) error {
+	if GormDB == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	result := GormDB.Delete(&models.Suppression{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("suppression %d not found", id)
+	}
+	return nil
+}
+
+// GetPatterns retrieves the patterns stored in the database, including disabled ones
+func GetPatterns() []models.SecretPattern {
+	var patterns []models.SecretPattern
+
+	if GormDB == nil || !DatabaseRequired {
+		return patterns
+	}
+
+	if result := GormDB.Order("name").Find(&patterns); result.Error != nil {
+		log.Error("Failed to get patterns:", result.Error)
+	}
+
+	return patterns
+}
+
+// GetPattern retrieves a stored pattern by name
+func GetPattern(name string) (models.SecretPattern, error) {
+	var pattern models.SecretPattern
+	if GormDB == nil {
+		return pattern, fmt.Errorf("database connection is nil")
+	}
+
+	result := GormDB.Where("name = ?", name).Limit(1).Find(&pattern)
+	if result.Error != nil {
+		return pattern, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return pattern, fmt.Errorf("pattern %q not found", name)
+	}
+	return pattern, nil
+}
+
+// SavePattern creates a stored pattern or replaces the one with the same name
+func SavePattern(pattern *models.SecretPattern) error {
+	if GormDB == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	if existing, err := GetPattern(pattern.Name); err == nil {
+		pattern.ID = existing.ID
+		pattern.CreatedAt = existing.CreatedAt
+	} else {
+		pattern.ID = 0
+	}
+
+	log.Infof("Saving pattern %q", pattern.Name)
+	return GormDB.Save(pattern).Error
+}
+
+// DeletePattern removes a stored pattern by name
+func DeletePattern(name string) error {
+	if GormDB == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	result := GormDB.Where("name = ?", name).Delete(&models.SecretPattern{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("pattern %q not found", name)
+	}
+	return nil
+}
@@ -52,6 +52,8 @@ func init() {
 	log.SetLevel(log.InfoLevel)
 
 	rootCmd.AddCommand(cmd.GetCliCmd())
+	rootCmd.AddCommand(cmd.GetBaselineCmd())
+	rootCmd.AddCommand(cmd.GetPatternsCmd())
 	rootCmd.AddCommand(serverCmd)
 
 	// Server command flags
@@ -0,0 +1,80 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// How the path of a deeplink is matched
+const (
+	DeeplinkPathLiteral = "literal"
+	DeeplinkPathPrefix  = "prefix"
+	DeeplinkPathPattern = "pattern"
+)
+
+// Deeplink is a URI an intent filter of an activity accepts. Host, Port and
+// Path are the declared values, so Host may be a *. wildcard and Path a
+// prefix or pattern; URI is a concrete example that matches them.
+type Deeplink struct {
+	Component string `json:"component"`
+	Exported  bool   `json:"exported"`
+	URI       string `json:"uri"`
+	Scheme    string `json:"scheme"`
+	Host      string `json:"host,omitempty"`
+	Port      string `json:"port,omitempty"`
+	Path      string `json:"path,omitempty"`
+	PathMatch string `json:"pathMatch,omitempty"`
+	Action    string `json:"action,omitempty"`
+	MimeType  string `json:"mimeType,omitempty"`
+	Browsable bool   `json:"browsable"`
+	AppLink   bool   `json:"appLink"`
+	Command   string `json:"command"`
+}
+
+// DeeplinkArray is a custom type for handling arrays of Deeplink in MySQL
+// JSON columns
+type DeeplinkArray []Deeplink
+
+// Scan implements sql.Scanner interface
+func (d *DeeplinkArray) Scan(value interface{}) error {
+	if value == nil {
+		*d = DeeplinkArray{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to unmarshal DeeplinkArray value")
+	}
+	if len(bytes) == 0 {
+		*d = DeeplinkArray{}
+		return nil
+	}
+
+	return json.Unmarshal(bytes, d)
+}
+
+// Value implements driver.Valuer interface
+func (d DeeplinkArray) Value() (driver.Value, error) {
+	if d == nil {
+		return json.Marshal(DeeplinkArray{})
+	}
+	return json.Marshal(d)
+}
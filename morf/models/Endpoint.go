@@ -0,0 +1,100 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// Endpoint types
+const (
+	EndpointURL    = "url"
+	EndpointHost   = "host"
+	EndpointIP     = "ip"
+	EndpointBucket = "bucket"
+)
+
+// EndpointLocation is one place in the APK where an endpoint is referenced
+type EndpointLocation struct {
+	FileLocation string `json:"fileLocation"`
+	LineNo       int    `json:"lineNo"`
+}
+
+// Endpoint is a backend, host or cloud storage bucket referenced by an APK
+type Endpoint struct {
+	Value       string             `json:"value"`
+	Type        string             `json:"type"`
+	Scheme      string             `json:"scheme,omitempty"`
+	Host        string             `json:"host,omitempty"`
+	Provider    string             `json:"provider,omitempty"`
+	Bucket      string             `json:"bucket,omitempty"`
+	Cleartext   bool               `json:"cleartext"`
+	Internal    bool               `json:"internal"`
+	Environment string             `json:"environment,omitempty"`
+	Locations   []EndpointLocation `json:"locations"`
+}
+
+// EndpointArray is a custom type for handling arrays of Endpoint in MySQL
+type EndpointArray []Endpoint
+
+// Scan implements sql.Scanner interface
+func (e *EndpointArray) Scan(value interface{}) error {
+	if value == nil {
+		*e = EndpointArray{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to unmarshal EndpointArray value")
+	}
+	if len(bytes) == 0 {
+		*e = EndpointArray{}
+		return nil
+	}
+
+	return json.Unmarshal(bytes, e)
+}
+
+// Value implements driver.Valuer interface
+func (e EndpointArray) Value() (driver.Value, error) {
+	if e == nil {
+		return json.Marshal(EndpointArray{})
+	}
+	return json.Marshal(e)
+}
+
+// EndpointQuery filters the endpoint inventory. Empty fields match everything.
+type EndpointQuery struct {
+	Text        string `form:"q"`
+	Type        string `form:"type"`
+	Provider    string `form:"provider"`
+	Environment string `form:"environment"`
+	Cleartext   *bool  `form:"cleartext"`
+	Internal    *bool  `form:"internal"`
+	PackageName string `form:"package"`
+}
+
+// EndpointSearchResult is an endpoint together with the APK referencing it
+type EndpointSearchResult struct {
+	FileName    string   `json:"fileName"`
+	PackageName string   `json:"packageName"`
+	Version     string   `json:"version"`
+	Endpoint    Endpoint `json:"endpoint"`
+}
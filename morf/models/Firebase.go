@@ -0,0 +1,83 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// Firebase check statuses
+const (
+	FirebaseCheckVulnerable = "vulnerable"
+	FirebaseCheckSecure     = "secure"
+	FirebaseCheckNotFound   = "not_found"
+	FirebaseCheckError      = "error"
+)
+
+// FirebaseCheck is the result of probing one Firebase or Google Cloud endpoint
+type FirebaseCheck struct {
+	Name       string `json:"name"`
+	Target     string `json:"target"`
+	Status     string `json:"status"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+}
+
+// FirebaseConfig is the Firebase and Google services configuration bundled in an APK
+type FirebaseConfig struct {
+	GoogleAPIKey         string          `json:"googleApiKey,omitempty"`
+	GoogleAppID          string          `json:"googleAppId,omitempty"`
+	DatabaseURL          string          `json:"firebaseDatabaseUrl,omitempty"`
+	StorageBucket        string          `json:"googleStorageBucket,omitempty"`
+	ProjectID            string          `json:"projectId,omitempty"`
+	GCMDefaultSenderID   string          `json:"gcmDefaultSenderId,omitempty"`
+	CrashReportingAPIKey string          `json:"googleCrashReportingApiKey,omitempty"`
+	DefaultWebClientID   string          `json:"defaultWebClientId,omitempty"`
+	Checks               []FirebaseCheck `json:"checks,omitempty"`
+}
+
+// IsEmpty reports whether no Firebase or Google services values were found
+func (f FirebaseConfig) IsEmpty() bool {
+	return f.GoogleAPIKey == "" && f.GoogleAppID == "" && f.DatabaseURL == "" && f.StorageBucket == "" &&
+		f.ProjectID == "" && f.GCMDefaultSenderID == "" && f.CrashReportingAPIKey == "" && f.DefaultWebClientID == ""
+}
+
+// Scan implements sql.Scanner interface
+func (f *FirebaseConfig) Scan(value interface{}) error {
+	if value == nil {
+		*f = FirebaseConfig{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to unmarshal FirebaseConfig value")
+	}
+	if len(bytes) == 0 {
+		*f = FirebaseConfig{}
+		return nil
+	}
+
+	return json.Unmarshal(bytes, f)
+}
+
+// Value implements driver.Valuer interface
+func (f FirebaseConfig) Value() (driver.Value, error) {
+	return json.Marshal(f)
+}
@@ -130,6 +130,7 @@ type MetaDataModel struct {
 		UsesMinSdkVersion          string                                   `json:"usesMinSdkVersion"`
 		UsesTargetSdkVersion       string                                   `json:"usesTargetSdkVersion"`
 		UsesMaxSdkVersion          string                                   `json:"usesMaxSdkVersion"`
+		Application                ManifestApplicationInfo                  `gorm:"type:json;column:application" json:"application"`
 	} `gorm:"embedded;" json:"androidManifest"`
 	CertificateDatas struct {
 		FileName         string `json:"fileName"`
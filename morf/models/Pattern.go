@@ -0,0 +1,57 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "time"
+
+// Sources of the patterns used by a scan
+const (
+	PatternSourceFile     = "file"
+	PatternSourceDatabase = "database"
+)
+
+// SecretPattern is a secret pattern managed through the API. A stored pattern
+// replaces the file-based pattern of the same name, and a disabled one removes it.
+type SecretPattern struct {
+	ID                 uint            `json:"id,omitempty" gorm:"primaryKey"`
+	Name               string          `json:"name" gorm:"column:name;size:191;uniqueIndex"`
+	Regex              string          `json:"regex" gorm:"column:regex;type:text" binding:"required"`
+	Confidence         string          `json:"confidence" gorm:"column:confidence" binding:"required"`
+	Keywords           JSONStringArray `json:"keywords,omitempty" gorm:"type:json;column:keywords"`
+	AllowlistRegexes   JSONStringArray `json:"allowlistRegexes,omitempty" gorm:"type:json;column:allowlist_regexes"`
+	AllowlistStopwords JSONStringArray `json:"allowlistStopwords,omitempty" gorm:"type:json;column:allowlist_stopwords"`
+	Paths              JSONStringArray `json:"paths,omitempty" gorm:"type:json;column:paths"`
+	ExcludePaths       JSONStringArray `json:"excludePaths,omitempty" gorm:"type:json;column:exclude_paths"`
+	Severity           string          `json:"severity,omitempty" gorm:"column:severity"`
+	CWE                string          `json:"cwe,omitempty" gorm:"column:cwe"`
+	Description        string          `json:"description,omitempty" gorm:"column:description;type:text"`
+	Remediation        string          `json:"remediation,omitempty" gorm:"column:remediation;type:text"`
+	SecretGroup        int             `json:"secretGroup,omitempty" gorm:"column:secret_group"`
+	Entropy            float64         `json:"entropy,omitempty" gorm:"column:entropy"`
+	Validator          string          `json:"validator,omitempty" gorm:"column:validator"`
+	Examples           JSONStringArray `json:"examples,omitempty" gorm:"type:json;column:examples"`
+	CounterExamples    JSONStringArray `json:"counterExamples,omitempty" gorm:"type:json;column:counter_examples"`
+	Disabled           bool            `json:"disabled" gorm:"column:disabled"`
+	Source             string          `json:"source" gorm:"-"`
+	CreatedAt          time.Time       `json:"createdAt,omitempty"`
+	UpdatedAt          time.Time       `json:"updatedAt,omitempty"`
+}
+
+// TableName specifies the table name for the SecretPattern model
+func (SecretPattern) TableName() string {
+	return "secret_patterns"
+}
@@ -20,16 +20,128 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"time"
 )
 
+// SecretContext holds the source lines surrounding a finding, starting at StartLine
+type SecretContext struct {
+	StartLine int      `json:"startLine"`
+	Lines     []string `json:"lines"`
+}
+
+// Results of the offline structural validation of a secret
+const (
+	ValidationValid   = "valid"
+	ValidationInvalid = "invalid"
+)
+
+// Outcomes of verifying a secret against its provider
+const (
+	VerifiedTrue    = "true"
+	VerifiedFalse   = "false"
+	VerifiedUnknown = "unknown"
+)
+
+// SecretVerification records whether a secret was accepted by its provider
+type SecretVerification struct {
+	Verified   string            `json:"verified"`
+	Verifier   string            `json:"verifier"`
+	StatusCode int               `json:"statusCode,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	CheckedAt  time.Time         `json:"checkedAt"`
+}
+
+// JWT weaknesses
+const (
+	JWTWeaknessAlgNone        = "alg_none"
+	JWTWeaknessSecretInApp    = "signing_secret_in_app"
+	JWTWeaknessNoExpiry       = "no_expiry"
+	JWTWeaknessLongExpiry     = "long_expiry"
+	JWTWeaknessPrivilegedRole = "privileged_claims"
+)
+
+// JWTDetails holds the decoded parts of a JSON Web Token. Claims that may
+// identify a user are partially masked.
+type JWTDetails struct {
+	Header     map[string]interface{} `json:"header"`
+	Claims     map[string]interface{} `json:"claims"`
+	Weaknesses []string               `json:"weaknesses,omitempty"`
+}
+
+// Names of the factors contributing to a finding's score
+const (
+	ScoreFactorBase         = "base"
+	ScoreFactorEntropy      = "entropy"
+	ScoreFactorPlaceholder  = "placeholder"
+	ScoreFactorValidation   = "validation"
+	ScoreFactorVerification = "verification"
+	ScoreFactorLocation     = "location"
+	ScoreFactorContext      = "context"
+)
+
+// ScoreFactor is one contribution to a finding's score
+type ScoreFactor struct {
+	Factor string `json:"factor"`
+	Points int    `json:"points"`
+	Detail string `json:"detail"`
+}
+
+// SecretQuery filters and orders the findings of a scan result
+type SecretQuery struct {
+	MinScore *int   `form:"min_score" binding:"omitempty,min=0,max=100"`
+	MaxScore *int   `form:"max_score" binding:"omitempty,min=0,max=100"`
+	Sort     string `form:"sort" binding:"omitempty,oneof=score"`
+	Order    string `form:"order" binding:"omitempty,oneof=asc desc"`
+}
+
+// SecretLocation is one place in the APK where a secret was found
+type SecretLocation struct {
+	FileLocation string         `json:"fileLocation"`
+	LineNo       int            `json:"lineNo"`
+	ColumnNo     int            `json:"columnNo"`
+	EndLineNo    int            `json:"endLineNo,omitempty"`
+	EndColumnNo  int            `json:"endColumnNo,omitempty"`
+	ClassName    string         `json:"className,omitempty"`
+	MethodName   string         `json:"methodName,omitempty"`
+	Section      string         `json:"section,omitempty"`
+	Offset       uint64         `json:"offset,omitempty"`
+	Patterns     []string       `json:"patterns"`
+	Context      *SecretContext `json:"context,omitempty"`
+}
+
 // SecretModel represents a single secret found in the code
 type SecretModel struct {
-	Type             string `json:"type"`
-	LineNo           int    `json:"lineNo"`
-	FileLocation     string `json:"fileLocation"`
-	SecretType       string `json:"secretType"`
-	SecretString     string `json:"secretString"`
-	SecretConfidence string `json:"secretConfidence"`
+	Fingerprint       string              `json:"fingerprint,omitempty"`
+	Type              string              `json:"type"`
+	LineNo            int                 `json:"lineNo"`
+	ColumnNo          int                 `json:"columnNo"`
+	EndLineNo         int                 `json:"endLineNo"`
+	EndColumnNo       int                 `json:"endColumnNo"`
+	FileLocation      string              `json:"fileLocation"`
+	ClassName         string              `json:"className,omitempty"`
+	MethodName        string              `json:"methodName,omitempty"`
+	Section           string              `json:"section,omitempty"`
+	Offset            uint64              `json:"offset,omitempty"`
+	SecretType        string              `json:"secretType"`
+	SecretString      string              `json:"secretString"`
+	Match             string              `json:"-"` // text the pattern matched, only kept for redaction
+	SecretConfidence  string              `json:"secretConfidence"`
+	Score             int                 `json:"score"`
+	ScoreFactors      []ScoreFactor       `json:"scoreFactors,omitempty"`
+	Validation        string              `json:"validation,omitempty"`
+	ValidationDetail  string              `json:"validationDetail,omitempty"`
+	Verification      *SecretVerification `json:"verification,omitempty"`
+	Entropy           float64             `json:"entropy,omitempty"`
+	DecodingChain     []string            `json:"decodingChain,omitempty"`
+	JWT               *JWTDetails         `json:"jwt,omitempty"`
+	Severity          string              `json:"severity,omitempty"`
+	CWE               string              `json:"cwe,omitempty"`
+	Description       string              `json:"description,omitempty"`
+	Remediation       string              `json:"remediation,omitempty"`
+	Context           *SecretContext      `json:"context,omitempty"`
+	Locations         []SecretLocation    `json:"locations,omitempty"`
+	Suppressed        bool                `json:"suppressed,omitempty"`
+	SuppressionReason string              `json:"suppressionReason,omitempty"`
 }
 
 // SecretModelArray is a custom type for handling arrays of SecretModel in MySQL
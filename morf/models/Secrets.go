@@ -33,6 +33,10 @@ type Secrets struct {
 	Services           JSONComponentArray[ManifestServiceInfo]  `json:"services" gorm:"type:json;column:services"`
 	ContentProviders   JSONComponentArray[ManifestProviderInfo] `json:"contentProviders" gorm:"type:json;column:content_providers"`
 	BroadcastReceivers JSONComponentArray[ManifestReceiverInfo] `json:"broadcastReceivers" gorm:"type:json;column:broadcast_receivers"`
+	Firebase           FirebaseConfig                           `json:"firebase" gorm:"type:json;column:firebase"`
+	Endpoints          EndpointArray                            `json:"endpoints" gorm:"type:json;column:endpoints"`
+	Deeplinks          DeeplinkArray                            `json:"deeplinks" gorm:"type:json;column:deeplinks"`
+	PatternSetVersion  string                                   `json:"patternSetVersion" gorm:"column:pattern_set_version"`
 }
 
 // BeforeSave ensures arrays are initialized before saving
@@ -0,0 +1,53 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "time"
+
+// Suppression marks a finding, identified by its fingerprint, as accepted or a false positive
+type Suppression struct {
+	ID            uint       `json:"id,omitempty" gorm:"primaryKey"`
+	PackageName   string     `json:"packageName,omitempty" gorm:"column:package_name;index"`
+	Fingerprint   string     `json:"fingerprint" gorm:"column:fingerprint;index" binding:"required"`
+	Type          string     `json:"type,omitempty" gorm:"column:type"`
+	Justification string     `json:"justification,omitempty" gorm:"column:justification"`
+	ExpiresAt     *time.Time `json:"expiresAt,omitempty" gorm:"column:expires_at"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+// Active reports whether the suppression still applies at the given time
+func (s Suppression) Active(now time.Time) bool {
+	return s.ExpiresAt == nil || now.Before(*s.ExpiresAt)
+}
+
+// AppliesTo reports whether the suppression covers findings of the given package.
+// Suppressions without a package name apply to every package.
+func (s Suppression) AppliesTo(packageName string) bool {
+	return s.PackageName == "" || s.PackageName == packageName
+}
+
+// TableName specifies the table name for the Suppression model
+func (Suppression) TableName() string {
+	return "suppressions"
+}
+
+// Baseline is the layout of a suppression baseline file
+type Baseline struct {
+	Version      int           `json:"version"`
+	CreatedAt    time.Time     `json:"createdAt"`
+	Suppressions []Suppression `json:"suppressions"`
+}
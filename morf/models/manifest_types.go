@@ -26,32 +26,78 @@ import (
 
 // Component types for Android manifest parsing
 
-// ManifestActivityInfo represents information about an Android activity
+// Protection labels of components, from how well their permission guards
+// keep other apps out
+const (
+	ComponentUnprotected     = "unprotected"
+	ComponentWeaklyProtected = "weakly_protected"
+	ComponentProtected       = "protected"
+)
+
+// ManifestActivityInfo represents information about an Android activity.
+// Activity aliases carry the name of the activity they launch in TargetActivity.
 type ManifestActivityInfo struct {
-	Name          string           `json:"name"`
-	Exported      bool             `json:"exported"`
-	IntentFilters []ManifestFilter `json:"intentFilters,omitempty"`
+	Name             string           `json:"name"`
+	TargetActivity   string           `json:"targetActivity,omitempty"`
+	Exported         bool             `json:"exported"`
+	ExportedReason   string           `json:"exportedReason,omitempty"`
+	Permission       string           `json:"permission,omitempty"`
+	Protection       string           `json:"protection,omitempty"`
+	ProtectionDetail string           `json:"protectionDetail,omitempty"`
+	IntentFilters    []ManifestFilter `json:"intentFilters,omitempty"`
 }
 
 // ManifestServiceInfo represents information about an Android service
 type ManifestServiceInfo struct {
-	Name          string           `json:"name"`
-	Exported      bool             `json:"exported"`
-	IntentFilters []ManifestFilter `json:"intentFilters,omitempty"`
+	Name             string           `json:"name"`
+	Exported         bool             `json:"exported"`
+	ExportedReason   string           `json:"exportedReason,omitempty"`
+	Permission       string           `json:"permission,omitempty"`
+	Protection       string           `json:"protection,omitempty"`
+	ProtectionDetail string           `json:"protectionDetail,omitempty"`
+	IntentFilters    []ManifestFilter `json:"intentFilters,omitempty"`
 }
 
 // ManifestReceiverInfo represents information about an Android broadcast receiver
 type ManifestReceiverInfo struct {
-	Name          string           `json:"name"`
-	Exported      bool             `json:"exported"`
-	IntentFilters []ManifestFilter `json:"intentFilters,omitempty"`
+	Name             string           `json:"name"`
+	Exported         bool             `json:"exported"`
+	ExportedReason   string           `json:"exportedReason,omitempty"`
+	Permission       string           `json:"permission,omitempty"`
+	Protection       string           `json:"protection,omitempty"`
+	ProtectionDetail string           `json:"protectionDetail,omitempty"`
+	IntentFilters    []ManifestFilter `json:"intentFilters,omitempty"`
 }
 
 // ManifestProviderInfo represents information about an Android content provider
 type ManifestProviderInfo struct {
-	Name        string   `json:"name"`
-	Exported    bool     `json:"exported"`
-	Authorities []string `json:"authorities,omitempty"`
+	Name                string                   `json:"name"`
+	Exported            bool                     `json:"exported"`
+	ExportedReason      string                   `json:"exportedReason,omitempty"`
+	Authorities         []string                 `json:"authorities,omitempty"`
+	Permission          string                   `json:"permission,omitempty"`
+	ReadPermission      string                   `json:"readPermission,omitempty"`
+	WritePermission     string                   `json:"writePermission,omitempty"`
+	GrantURIPermissions bool                     `json:"grantUriPermissions,omitempty"`
+	PathPermissions     []ManifestPathPermission `json:"pathPermissions,omitempty"`
+	GrantURIPaths       []ManifestURIPath        `json:"grantUriPaths,omitempty"`
+	Protection          string                   `json:"protection,omitempty"`
+	ProtectionDetail    string                   `json:"protectionDetail,omitempty"`
+}
+
+// ManifestURIPath is the path, prefix or pattern a provider rule applies to
+type ManifestURIPath struct {
+	Path        string `json:"path,omitempty"`
+	PathPrefix  string `json:"pathPrefix,omitempty"`
+	PathPattern string `json:"pathPattern,omitempty"`
+}
+
+// ManifestPathPermission is a <path-permission> of a content provider
+type ManifestPathPermission struct {
+	ManifestURIPath
+	Permission      string `json:"permission,omitempty"`
+	ReadPermission  string `json:"readPermission,omitempty"`
+	WritePermission string `json:"writePermission,omitempty"`
 }
 
 // ManifestFilter represents an intent filter in the Android manifest
@@ -74,6 +120,65 @@ type ManifestFilterData struct {
 	MimeType    string   `json:"mimeType,omitempty"`
 }
 
+// Severities of manifest findings
+const (
+	ManifestSeverityHigh   = "high"
+	ManifestSeverityMedium = "medium"
+	ManifestSeverityLow    = "low"
+	ManifestSeverityInfo   = "info"
+)
+
+// ManifestFinding is a risky manifest setting
+type ManifestFinding struct {
+	ID          string `json:"id"`
+	Attribute   string `json:"attribute"`
+	Value       string `json:"value"`
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+}
+
+// ManifestApplicationInfo holds the security relevant attributes of
+// <application>, and sharedUserId from <manifest>. Booleans are nil when the
+// attribute is absent so that platform defaults can be told apart from
+// explicit values; resource references are kept as @0x7f... IDs.
+type ManifestApplicationInfo struct {
+	Debuggable                   *bool             `json:"debuggable,omitempty"`
+	AllowBackup                  *bool             `json:"allowBackup,omitempty"`
+	FullBackupContent            string            `json:"fullBackupContent,omitempty"`
+	DataExtractionRules          string            `json:"dataExtractionRules,omitempty"`
+	UsesCleartextTraffic         *bool             `json:"usesCleartextTraffic,omitempty"`
+	NetworkSecurityConfig        string            `json:"networkSecurityConfig,omitempty"`
+	TestOnly                     *bool             `json:"testOnly,omitempty"`
+	SharedUserID                 string            `json:"sharedUserId,omitempty"`
+	ExtractNativeLibs            *bool             `json:"extractNativeLibs,omitempty"`
+	RequestLegacyExternalStorage *bool             `json:"requestLegacyExternalStorage,omitempty"`
+	Findings                     []ManifestFinding `json:"findings,omitempty"`
+}
+
+// Scan implements sql.Scanner interface
+func (a *ManifestApplicationInfo) Scan(value interface{}) error {
+	if value == nil {
+		*a = ManifestApplicationInfo{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to unmarshal ManifestApplicationInfo value")
+	}
+	if len(bytes) == 0 {
+		*a = ManifestApplicationInfo{}
+		return nil
+	}
+
+	return json.Unmarshal(bytes, a)
+}
+
+// Value implements driver.Valuer interface
+func (a ManifestApplicationInfo) Value() (driver.Value, error) {
+	return json.Marshal(a)
+}
+
 // Custom types for database handling
 
 // JSONComponentArray is a custom type for handling arrays of components in MySQL JSON columns
@@ -25,14 +25,19 @@ func NewAPIResponseHandler(secret models.Secrets, scannerData []models.SecretMod
 // CreateBasicResponse creates a basic response with common fields
 func (h *APIResponseHandler) CreateBasicResponse() gin.H {
 	return gin.H{
-		"fileName":    h.secret.FileName,
-		"packageName": h.secret.PackageDataModel.PackageName,
-		"version":     h.secret.PackageDataModel.VersionName,
-		"minSdk":      h.secret.Metadata.AndroidManifest.UsesMinSdkVersion,
-		"targetSdk":   h.secret.Metadata.AndroidManifest.UsesTargetSdkVersion,
-		"permissions": h.secret.Metadata.AndroidManifest.UsesPermissions,
-		"secretCount": len(h.scannerData),
-		"secrets":     h.scannerData,
+		"fileName":          h.secret.FileName,
+		"packageName":       h.secret.PackageDataModel.PackageName,
+		"version":           h.secret.PackageDataModel.VersionName,
+		"minSdk":            h.secret.Metadata.AndroidManifest.UsesMinSdkVersion,
+		"targetSdk":         h.secret.Metadata.AndroidManifest.UsesTargetSdkVersion,
+		"permissions":       h.secret.Metadata.AndroidManifest.UsesPermissions,
+		"application":       h.secret.Metadata.AndroidManifest.Application,
+		"secretCount":       len(h.scannerData),
+		"secrets":           h.scannerData,
+		"firebase":          h.secret.Firebase,
+		"endpoints":         h.secret.Endpoints,
+		"deeplinks":         h.secret.Deeplinks,
+		"patternSetVersion": h.secret.PatternSetVersion,
 	}
 }
 
@@ -61,3 +66,52 @@ func ParseExistingSecret(jsonData string) (models.Secrets, error) {
 	err := json.Unmarshal([]byte(jsonData), &existingSecret)
 	return existingSecret, err
 }
+
+// RedactResponseSecrets returns a copy of a scan response with its secrets
+// passed through redact, which may also filter them. secretCount is updated
+// to the number of secrets left.
+func RedactResponseSecrets(resp gin.H, redact func([]models.SecretModel) []models.SecretModel) gin.H {
+	data, ok := resp["data"].(gin.H)
+	if !ok {
+		return resp
+	}
+	secrets, ok := data["secrets"].([]models.SecretModel)
+	if !ok {
+		return resp
+	}
+	secrets = redact(secrets)
+	return replaceResponseData(resp, data, gin.H{"secrets": secrets, "secretCount": len(secrets)})
+}
+
+// RedactResponseFirebase returns a copy of a scan response with the API keys
+// of its Firebase configuration masked
+func RedactResponseFirebase(resp gin.H, mode string) gin.H {
+	data, ok := resp["data"].(gin.H)
+	if !ok {
+		return resp
+	}
+	firebase, ok := data["firebase"].(models.FirebaseConfig)
+	if !ok {
+		return resp
+	}
+	return replaceResponseData(resp, data, gin.H{"firebase": utils.RedactFirebase(firebase, mode)})
+}
+
+// replaceResponseData returns a copy of a scan response with fields of its
+// data replaced, leaving the original response unchanged
+func replaceResponseData(resp gin.H, data gin.H, fields gin.H) gin.H {
+	redactedData := gin.H{}
+	for k, v := range data {
+		redactedData[k] = v
+	}
+	for k, v := range fields {
+		redactedData[k] = v
+	}
+
+	redacted := gin.H{}
+	for k, v := range resp {
+		redacted[k] = v
+	}
+	redacted["data"] = redactedData
+	return redacted
+}
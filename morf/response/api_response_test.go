@@ -0,0 +1,64 @@
+package response
+
+import (
+	"morf/models"
+	"morf/utils"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	vip "github.com/spf13/viper"
+)
+
+func TestRedactResponseFirebase(t *testing.T) {
+	vip.Set("redaction.partial_prefix", 4)
+	vip.Set("redaction.partial_suffix", 2)
+	defer vip.Reset()
+
+	apiKey := "AIzaSyA1234567890abcdefghijklmnopqrstu"
+	secret := models.Secrets{Firebase: models.FirebaseConfig{
+		GoogleAPIKey:         apiKey,
+		CrashReportingAPIKey: apiKey,
+		ProjectID:            "example-project",
+	}}
+	resp := NewAPIResponseHandler(secret, nil).CreateSuccessResponse()
+
+	masked := RedactResponseFirebase(resp, utils.RedactPartial)
+	firebase := masked["data"].(gin.H)["firebase"].(models.FirebaseConfig)
+	want := "AIza" + strings.Repeat("*", len(apiKey)-6) + "tu"
+	if firebase.GoogleAPIKey != want || firebase.CrashReportingAPIKey != want {
+		t.Errorf("got keys %q and %q, want %q", firebase.GoogleAPIKey, firebase.CrashReportingAPIKey, want)
+	}
+	if firebase.ProjectID != "example-project" {
+		t.Errorf("project ID should not be masked, got %q", firebase.ProjectID)
+	}
+
+	// The original response is left unchanged, and unmasked requests get the raw key
+	if original := resp["data"].(gin.H)["firebase"].(models.FirebaseConfig); original.GoogleAPIKey != apiKey {
+		t.Errorf("original response was modified: %q", original.GoogleAPIKey)
+	}
+	unmasked := RedactResponseFirebase(resp, utils.RedactNone)
+	if got := unmasked["data"].(gin.H)["firebase"].(models.FirebaseConfig).GoogleAPIKey; got != apiKey {
+		t.Errorf("unmasked key = %q, want %q", got, apiKey)
+	}
+}
+
+func TestRedactResponseSecretsCount(t *testing.T) {
+	low, high := 20, 90
+	secrets := []models.SecretModel{{Type: "A", Score: low}, {Type: "B", Score: high}}
+	resp := NewAPIResponseHandler(models.Secrets{}, secrets).CreateSuccessResponse()
+
+	filtered := RedactResponseSecrets(resp, func(secrets []models.SecretModel) []models.SecretModel {
+		return utils.QuerySecrets(secrets, models.SecretQuery{MinScore: &high})
+	})
+	data := filtered["data"].(gin.H)
+	if got := data["secrets"].([]models.SecretModel); len(got) != 1 || got[0].Type != "B" {
+		t.Fatalf("unexpected secrets %+v", got)
+	}
+	if data["secretCount"] != 1 {
+		t.Errorf("secretCount = %v, want the 1 secret left after filtering", data["secretCount"])
+	}
+	if resp["data"].(gin.H)["secretCount"] != 2 {
+		t.Errorf("original secretCount changed to %v", resp["data"].(gin.H)["secretCount"])
+	}
+}
@@ -21,9 +21,11 @@ import (
 	"morf/apk"
 	"morf/db"
 	"morf/models"
+	"morf/response"
 	"morf/utils"
 	"net/http"
 	"path/filepath"
+	"strconv"
 	"sync"
 
 	"github.com/gin-gonic/gin"
@@ -73,11 +75,31 @@ func InitRouters(router *gin.RouterGroup) *gin.RouterGroup {
 
 	router.GET("/results/:filename", func(c *gin.Context) {
 		filename := c.Param("filename")
+		query := models.SecretQuery{}
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		mapMutex.Lock()
 		result, exists := resultsMap[filename]
 		if exists {
 			delete(resultsMap, filename)
 			mapMutex.Unlock()
+			// Secret values are masked unless the caller explicitly asks for them
+			mode := utils.RedactionMode(utils.SinkAPI)
+			if c.Query("unmasked") == "true" {
+				mode = utils.RedactNone
+			}
+			redactContext := c.Query("redact_context") == "true"
+			result = response.RedactResponseSecrets(result, func(secrets []models.SecretModel) []models.SecretModel {
+				secrets = utils.QuerySecrets(secrets, query)
+				if redactContext {
+					secrets = utils.RedactContext(secrets)
+				}
+				return utils.RedactSecrets(secrets, mode)
+			})
+			result = response.RedactResponseFirebase(result, mode)
 			c.JSON(http.StatusOK, result)
 		} else {
 			mapMutex.Unlock()
@@ -195,5 +217,176 @@ func InitRouters(router *gin.RouterGroup) *gin.RouterGroup {
 		}()
 	})
 
+	router.GET("/endpoints", func(ctx *gin.Context) {
+		query := models.EndpointQuery{}
+		if err := ctx.ShouldBindQuery(&query); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := checkDatabaseStatus(); err != nil {
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+
+		results := utils.SearchEndpoints(db.GetEndpointInventory(query.PackageName), query)
+		ctx.JSON(http.StatusOK, gin.H{"count": len(results), "endpoints": results})
+	})
+
+	router.GET("/patterns", func(ctx *gin.Context) {
+		patterns := apk.ListPatterns()
+		ctx.JSON(http.StatusOK, gin.H{"version": apk.LoadPatternSet().Version, "count": len(patterns), "patterns": patterns})
+	})
+
+	router.POST("/patterns", func(ctx *gin.Context) {
+		pattern := models.SecretPattern{}
+		if err := ctx.ShouldBindJSON(&pattern); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := apk.ValidatePattern(pattern); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := checkDatabaseStatus(); err != nil {
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+
+		if _, err := db.GetPattern(pattern.Name); err == nil {
+			ctx.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("pattern %q already exists", pattern.Name)})
+			return
+		}
+		if err := db.SavePattern(&pattern); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		pattern.Source = models.PatternSourceDatabase
+		ctx.JSON(http.StatusCreated, pattern)
+	})
+
+	router.PUT("/patterns/:name", func(ctx *gin.Context) {
+		pattern := models.SecretPattern{}
+		if err := ctx.ShouldBindJSON(&pattern); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		pattern.Name = ctx.Param("name")
+		if err := apk.ValidatePattern(pattern); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := checkDatabaseStatus(); err != nil {
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Updating a file-based pattern stores an override of it
+		if err := db.SavePattern(&pattern); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		pattern.Source = models.PatternSourceDatabase
+		ctx.JSON(http.StatusOK, pattern)
+	})
+
+	setPatternDisabled := func(ctx *gin.Context, disabled bool) {
+		if err := checkDatabaseStatus(); err != nil {
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+
+		name := ctx.Param("name")
+		pattern, err := db.GetPattern(name)
+		if err != nil {
+			filePattern, ok := apk.FilePattern(name)
+			if !ok {
+				ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			pattern = filePattern
+		}
+
+		pattern.Disabled = disabled
+		if err := db.SavePattern(&pattern); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		pattern.Source = models.PatternSourceDatabase
+		ctx.JSON(http.StatusOK, pattern)
+	}
+
+	router.POST("/patterns/:name/disable", func(ctx *gin.Context) {
+		setPatternDisabled(ctx, true)
+	})
+
+	router.POST("/patterns/:name/enable", func(ctx *gin.Context) {
+		setPatternDisabled(ctx, false)
+	})
+
+	router.DELETE("/patterns/:name", func(ctx *gin.Context) {
+		if err := checkDatabaseStatus(); err != nil {
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+
+		// File-based patterns come back once their stored override is deleted
+		if err := db.DeletePattern(ctx.Param("name")); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"message": "Pattern removed"})
+	})
+
+	router.GET("/suppressions", func(ctx *gin.Context) {
+		if err := checkDatabaseStatus(); err != nil {
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"suppressions": db.GetSuppressions(ctx.Query("package"))})
+	})
+
+	router.POST("/suppressions", func(ctx *gin.Context) {
+		suppression := models.Suppression{}
+		if err := ctx.ShouldBindJSON(&suppression); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := checkDatabaseStatus(); err != nil {
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+
+		suppression.ID = 0
+		if err := db.InsertSuppression(&suppression); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusCreated, suppression)
+	})
+
+	router.DELETE("/suppressions/:id", func(ctx *gin.Context) {
+		id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid suppression id"})
+			return
+		}
+
+		if err := checkDatabaseStatus(); err != nil {
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := db.DeleteSuppression(uint(id)); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"message": "Suppression removed"})
+	})
+
 	return router
 }
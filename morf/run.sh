@@ -19,12 +19,6 @@ if ! command -v go &> /dev/null; then
     exit 1
 fi
 
-# Check if AAPT is installed
-if ! command -v aapt &> /dev/null; then
-    echo -e "${YELLOW}Warning: AAPT is not installed or not in PATH${NC}"
-    echo "Using bundled AAPT in tools directory if available"
-fi
-
 # Build the application
 echo -e "${GREEN}Building MORF...${NC}"
 go build -o morf main.go
@@ -0,0 +1,155 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"morf/models"
+	"os"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	alf "github.com/spf13/afero"
+	vip "github.com/spf13/viper"
+)
+
+// BaselineVersion is the layout version written to new baseline files
+const BaselineVersion = 1
+
+// GetBaselinePath returns the configured suppression baseline file
+func GetBaselinePath() string {
+	return vip.GetString("baseline_path")
+}
+
+// LoadBaseline reads a baseline file. A missing file is an empty baseline.
+func LoadBaseline(fs alf.Fs, path string) (models.Baseline, error) {
+	var baseline models.Baseline
+	if path == "" {
+		return baseline, nil
+	}
+
+	data, err := alf.ReadFile(fs, path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return baseline, nil
+		}
+		return baseline, fmt.Errorf("failed to read baseline %s: %v", path, err)
+	}
+
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return baseline, fmt.Errorf("failed to parse baseline %s: %v", path, err)
+	}
+	return baseline, nil
+}
+
+// SaveBaseline writes a baseline file with its suppressions ordered by fingerprint
+func SaveBaseline(fs alf.Fs, path string, baseline models.Baseline) error {
+	sort.SliceStable(baseline.Suppressions, func(i, j int) bool {
+		return baseline.Suppressions[i].Fingerprint < baseline.Suppressions[j].Fingerprint
+	})
+
+	data, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode baseline: %v", err)
+	}
+	return alf.WriteFile(fs, path, data, 0644)
+}
+
+// AddToBaseline records a suppression for every finding not already in the baseline
+// and returns how many were added
+func AddToBaseline(baseline *models.Baseline, packageName string, findings []models.SecretModel, justification string, expiresAt *time.Time) int {
+	known := make(map[string]bool)
+	for _, suppression := range baseline.Suppressions {
+		known[suppression.PackageName+"\x00"+suppression.Fingerprint] = true
+	}
+
+	added := 0
+	now := time.Now().UTC()
+	for _, finding := range findings {
+		fingerprint := finding.Fingerprint
+		if fingerprint == "" {
+			fingerprint = SecretFingerprint(finding.Type, finding.SecretString)
+		}
+		key := packageName + "\x00" + fingerprint
+		if known[key] {
+			continue
+		}
+		known[key] = true
+
+		baseline.Suppressions = append(baseline.Suppressions, models.Suppression{
+			PackageName:   packageName,
+			Fingerprint:   fingerprint,
+			Type:          finding.Type,
+			Justification: justification,
+			ExpiresAt:     expiresAt,
+			CreatedAt:     now,
+		})
+		added++
+	}
+	return added
+}
+
+// ApplySuppressions marks the findings of a package covered by an active suppression.
+// Findings whose suppression has expired are reported again.
+func ApplySuppressions(findings []models.SecretModel, packageName string, suppressions []models.Suppression, now time.Time) []models.SecretModel {
+	active := make(map[string]models.Suppression)
+	for _, suppression := range suppressions {
+		if suppression.AppliesTo(packageName) && suppression.Active(now) {
+			active[suppression.Fingerprint] = suppression
+		}
+	}
+
+	marked := make([]models.SecretModel, len(findings))
+	for i, finding := range findings {
+		fingerprint := finding.Fingerprint
+		if fingerprint == "" {
+			fingerprint = SecretFingerprint(finding.Type, finding.SecretString)
+		}
+
+		finding.Suppressed = false
+		finding.SuppressionReason = ""
+		if suppression, ok := active[fingerprint]; ok {
+			finding.Suppressed = true
+			finding.SuppressionReason = suppression.Justification
+			if finding.SuppressionReason == "" {
+				finding.SuppressionReason = "baseline"
+			}
+		}
+		marked[i] = finding
+	}
+	return marked
+}
+
+// UnsuppressedSecrets returns the findings that are not suppressed
+func UnsuppressedSecrets(findings []models.SecretModel) []models.SecretModel {
+	var visible []models.SecretModel
+	for _, finding := range findings {
+		if !finding.Suppressed {
+			visible = append(visible, finding)
+		}
+	}
+	return visible
+}
+
+// logSuppressed reports how many findings a sink left out
+func logSuppressed(sink string, total int, visible int) {
+	if total > visible {
+		log.Infof("%s: %d suppressed findings omitted", sink, total-visible)
+	}
+}
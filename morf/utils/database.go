@@ -96,7 +96,8 @@ func CookJiraComment(jiraModel models.JiraModel, secret models.Secrets, ctx *gin
 }
 
 func parseJiraMessage(secrets models.Secrets) []string {
-	secretModel := secrets.SecretModel
+	secretModel := UnsuppressedSecrets(secrets.SecretModel)
+	logSuppressed("Jira", len(secrets.SecretModel), len(secretModel))
 
 	var messages []string
 	var currentMessage string
@@ -111,14 +112,15 @@ func parseJiraMessage(secrets models.Secrets) []string {
 		strconv.Itoa(len(secretModel)) + " secrets found\n" +
 		"----------------\n"
 
-	for _, value := range []models.SecretModel(secretModel) {
+	for _, value := range RedactSecrets(secretModel, RedactionMode(SinkJira)) {
 		heading := value.Type
 		headingMarkup := fmt.Sprintf("\n === %s ===\n", heading)
 		secretEntry := "{noformat}" +
 			headingMarkup +
 			"Secret Value: " + value.SecretString + "\n" +
-			"Line No: " + strconv.Itoa(value.LineNo) + "\n" +
-			"File Location: " + value.FileLocation + "\n" +
+			"Fingerprint: " + value.Fingerprint + "\n" +
+			FormatLocations(value) +
+			FormatPatternDetails(value) +
 			"{noformat}"
 
 		if len(currentMessage)+len(secretEntry) > 32767 { // Jira has a 32,767 character limit per comment
@@ -0,0 +1,73 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"morf/models"
+	"strings"
+)
+
+// SearchEndpoints returns the endpoints of the scanned APKs matching query
+func SearchEndpoints(secrets []models.Secrets, query models.EndpointQuery) []models.EndpointSearchResult {
+	results := []models.EndpointSearchResult{}
+	for _, secret := range secrets {
+		if query.PackageName != "" && secret.PackageDataModel.PackageName != query.PackageName {
+			continue
+		}
+		for _, endpoint := range secret.Endpoints {
+			if !endpointMatches(endpoint, query) {
+				continue
+			}
+			results = append(results, models.EndpointSearchResult{
+				FileName:    secret.FileName,
+				PackageName: secret.PackageDataModel.PackageName,
+				Version:     secret.PackageDataModel.VersionName,
+				Endpoint:    endpoint,
+			})
+		}
+	}
+	return results
+}
+
+// endpointMatches reports whether an endpoint passes every filter of query.
+// The text filter is a case-insensitive substring of the value, host or bucket.
+func endpointMatches(endpoint models.Endpoint, query models.EndpointQuery) bool {
+	if query.Text != "" {
+		text := strings.ToLower(query.Text)
+		if !strings.Contains(strings.ToLower(endpoint.Value), text) &&
+			!strings.Contains(endpoint.Host, text) &&
+			!strings.Contains(strings.ToLower(endpoint.Bucket), text) {
+			return false
+		}
+	}
+	if query.Type != "" && !strings.EqualFold(endpoint.Type, query.Type) {
+		return false
+	}
+	if query.Provider != "" && !strings.EqualFold(endpoint.Provider, query.Provider) {
+		return false
+	}
+	if query.Environment != "" && !strings.EqualFold(endpoint.Environment, query.Environment) {
+		return false
+	}
+	if query.Cleartext != nil && endpoint.Cleartext != *query.Cleartext {
+		return false
+	}
+	if query.Internal != nil && endpoint.Internal != *query.Internal {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,47 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"morf/models"
+	"testing"
+)
+
+func TestSearchEndpoints(t *testing.T) {
+	secrets := []models.Secrets{{
+		FileName:         "app.apk",
+		PackageDataModel: models.PackageDataModel{PackageName: "com.example", VersionName: "1.0"},
+		Endpoints: models.EndpointArray{
+			{Value: "http://api.staging.example.com", Type: models.EndpointURL, Host: "api.staging.example.com", Cleartext: true, Environment: "staging"},
+			{Value: "https://api.example.com", Type: models.EndpointURL, Host: "api.example.com"},
+			{Value: "gs://media", Type: models.EndpointBucket, Host: "media", Provider: "gcs", Bucket: "media"},
+		},
+	}}
+
+	cleartext := true
+	results := SearchEndpoints(secrets, models.EndpointQuery{Text: "EXAMPLE", Cleartext: &cleartext})
+	if len(results) != 1 || results[0].Endpoint.Value != "http://api.staging.example.com" || results[0].PackageName != "com.example" {
+		t.Errorf("SearchEndpoints = %+v", results)
+	}
+
+	if results := SearchEndpoints(secrets, models.EndpointQuery{Provider: "gcs"}); len(results) != 1 {
+		t.Errorf("provider filter returned %d results, want 1", len(results))
+	}
+	if results := SearchEndpoints(secrets, models.EndpointQuery{PackageName: "com.other"}); len(results) != 0 {
+		t.Errorf("package filter returned %d results, want 0", len(results))
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"encoding/hex"
 	"io"
 	"os"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -44,4 +45,16 @@ func ExtractHash(filePath string) string {
 	return hex.EncodeToString(hash.Sum(nil)[:16])
 }
 
+// NormalizeSecret strips the quoting and whitespace that surround a secret in source
+func NormalizeSecret(secret string) string {
+	return strings.Trim(strings.TrimSpace(secret), "\"'`;,")
+}
+
+// SecretFingerprint derives a stable identifier for a secret from the pattern
+// that found it and its normalized value
+func SecretFingerprint(pattern string, secret string) string {
+	hash := sha256.Sum256([]byte(pattern + "\x00" + NormalizeSecret(secret)))
+	return hex.EncodeToString(hash[:16])
+}
+
 // ValidateHash checks if a file matches a given hash
@@ -0,0 +1,168 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"morf/models"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	vip "github.com/spf13/viper"
+)
+
+// Redaction modes for secret values
+const (
+	RedactFull    = "full"
+	RedactPartial = "partial"
+	RedactHash    = "hash"
+	RedactNone    = "none"
+)
+
+// Sinks that secret values are written to, each with its own redaction setting
+const (
+	SinkStdout = "stdout"
+	SinkLog    = "log"
+	SinkBackup = "backup"
+	SinkSlack  = "slack"
+	SinkJira   = "jira"
+	SinkAPI    = "api"
+)
+
+// redactedPlaceholder replaces a fully redacted secret
+const redactedPlaceholder = "[REDACTED]"
+
+// RedactionMode returns the mode configured for a sink as redaction.<sink>,
+// falling back to redaction.default. Unknown modes are treated as full redaction.
+func RedactionMode(sink string) string {
+	mode := vip.GetString("redaction." + sink)
+	if mode == "" {
+		mode = vip.GetString("redaction.default")
+	}
+
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case RedactFull, RedactPartial, RedactHash, RedactNone:
+		return mode
+	case "":
+		return RedactPartial
+	}
+	log.Warnf("Unknown redaction mode %q for %s, redacting fully", mode, sink)
+	return RedactFull
+}
+
+// MaskSecret renders a secret value according to a redaction mode. Partial
+// redaction keeps redaction.partial_prefix and redaction.partial_suffix
+// characters, unless that would reveal most of a short secret.
+func MaskSecret(secret string, mode string) string {
+	if secret == "" {
+		return secret
+	}
+
+	switch mode {
+	case RedactNone:
+		return secret
+	case RedactHash:
+		hash := sha256.Sum256([]byte(secret))
+		return "sha256:" + hex.EncodeToString(hash[:6])
+	case RedactPartial:
+		runes := []rune(secret)
+		prefix := max(0, vip.GetInt("redaction.partial_prefix"))
+		suffix := max(0, vip.GetInt("redaction.partial_suffix"))
+		if prefix+suffix > len(runes)/2 {
+			return strings.Repeat("*", len(runes))
+		}
+		return string(runes[:prefix]) + strings.Repeat("*", len(runes)-prefix-suffix) + string(runes[len(runes)-suffix:])
+	}
+	return redactedPlaceholder
+}
+
+// MaskInText replaces every occurrence of a secret within text
+func MaskInText(text string, secret string, mode string) string {
+	if mode == RedactNone || secret == "" {
+		return text
+	}
+	return strings.ReplaceAll(text, secret, MaskSecret(secret, mode))
+}
+
+// RedactSecrets returns copies of the findings with their secret values masked,
+// including inside context snippets. The findings themselves are left unchanged.
+func RedactSecrets(findings []models.SecretModel, mode string) []models.SecretModel {
+	if mode == RedactNone {
+		return findings
+	}
+	return redactFindings(findings, func(secret string) string { return MaskSecret(secret, mode) }, mode)
+}
+
+// RedactFirebase returns a copy of a Firebase configuration with its API keys
+// masked
+func RedactFirebase(config models.FirebaseConfig, mode string) models.FirebaseConfig {
+	config.GoogleAPIKey = MaskSecret(config.GoogleAPIKey, mode)
+	config.CrashReportingAPIKey = MaskSecret(config.CrashReportingAPIKey, mode)
+	return config
+}
+
+// RedactContext returns copies of the findings whose context snippets no longer
+// contain the secret values. The findings themselves are left unchanged.
+func RedactContext(findings []models.SecretModel) []models.SecretModel {
+	return redactFindings(findings, func(secret string) string { return secret }, RedactFull)
+}
+
+// redactFindings rewrites each secret value with mask and replaces it inside
+// context snippets according to snippetMode
+func redactFindings(findings []models.SecretModel, mask func(string) string, snippetMode string) []models.SecretModel {
+	if findings == nil {
+		return nil
+	}
+
+	redacted := make([]models.SecretModel, len(findings))
+	for i, finding := range findings {
+		// The match goes first so that a secret inside it is masked along with it
+		secrets := []string{finding.Match, NormalizeSecret(finding.SecretString)}
+		finding.SecretString = mask(finding.SecretString)
+		finding.Match = ""
+		finding.Context = redactSnippet(finding.Context, secrets, snippetMode)
+
+		if finding.Locations != nil {
+			locations := make([]models.SecretLocation, len(finding.Locations))
+			for j, location := range finding.Locations {
+				location.Context = redactSnippet(location.Context, secrets, snippetMode)
+				locations[j] = location
+			}
+			finding.Locations = locations
+		}
+		redacted[i] = finding
+	}
+	return redacted
+}
+
+// redactSnippet copies a snippet with every occurrence of the secrets masked
+func redactSnippet(context *models.SecretContext, secrets []string, mode string) *models.SecretContext {
+	if context == nil {
+		return context
+	}
+
+	lines := make([]string, len(context.Lines))
+	for i, line := range context.Lines {
+		for _, secret := range secrets {
+			line = MaskInText(line, secret, mode)
+		}
+		lines[i] = line
+	}
+	return &models.SecretContext{StartLine: context.StartLine, Lines: lines}
+}
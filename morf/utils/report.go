@@ -17,7 +17,11 @@ limitations under the License.
 package utils
 
 import (
+	"encoding/json"
+	"fmt"
 	"morf/models"
+	"strconv"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 	alf "github.com/spf13/afero"
@@ -26,6 +30,10 @@ import (
 
 // CreateReport generates a report from scan results
 func CreateReport(fs alf.Fs, secret models.Secrets, json_data []byte, secret_data []byte, fileName string) {
+	if mode := RedactionMode(SinkBackup); mode != RedactNone {
+		json_data, secret_data = redactReport(secret, mode)
+	}
+
 	// Write full report
 	WriteToFile(fs, vip.GetString("backup_path")+fileName+"_"+secret.PackageDataModel.VersionName+".json", string(json_data))
 	WriteToFile(fs, "results"+"/"+fileName+"_"+secret.PackageDataModel.VersionName+".json", string(json_data))
@@ -36,3 +44,91 @@ func CreateReport(fs alf.Fs, secret models.Secrets, json_data []byte, secret_dat
 
 	log.Info("APK Data saved to: " + vip.GetString("backup_path") + "/" + fileName + "_" + secret.PackageDataModel.VersionName + ".json")
 }
+
+// redactReport re-encodes a report with its secret values masked
+func redactReport(secret models.Secrets, mode string) ([]byte, []byte) {
+	secret.SecretModel = RedactSecrets(secret.SecretModel, mode)
+	secret.Firebase = RedactFirebase(secret.Firebase, mode)
+
+	json_data, err := json.MarshalIndent(secret, "", " ")
+	if err != nil {
+		log.Error("Failed to marshal redacted report:", err)
+	}
+	secret_data, err := json.Marshal([]models.SecretModel(secret.SecretModel))
+	if err != nil {
+		log.Error("Failed to marshal redacted secrets:", err)
+	}
+	return json_data, secret_data
+}
+
+// FormatPatternDetails renders the optional pattern metadata of a secret, one field per line
+func FormatPatternDetails(secret models.SecretModel) string {
+	details := ""
+	if len(secret.DecodingChain) > 0 {
+		details += "Decoded Via: " + strings.Join(secret.DecodingChain, " > ") + "\n"
+	}
+	if len(secret.ScoreFactors) > 0 {
+		details += fmt.Sprintf("Score: %d (%s)\n", secret.Score, scoreFactorSummary(secret.ScoreFactors))
+	}
+	if secret.Validation != "" {
+		details += "Validation: " + secret.Validation + " (" + secret.ValidationDetail + ")\n"
+	}
+	if secret.Verification != nil {
+		details += "Verified: " + secret.Verification.Verified + " (" + secret.Verification.Verifier + ")\n"
+	}
+	if secret.Severity != "" {
+		details += "Severity: " + secret.Severity + "\n"
+	}
+	if secret.JWT != nil && len(secret.JWT.Weaknesses) > 0 {
+		details += "JWT Weaknesses: " + strings.Join(secret.JWT.Weaknesses, ", ") + "\n"
+	}
+	if secret.CWE != "" {
+		details += "CWE: " + secret.CWE + "\n"
+	}
+	if secret.Description != "" {
+		details += "Description: " + secret.Description + "\n"
+	}
+	if secret.Remediation != "" {
+		details += "Remediation: " + secret.Remediation + "\n"
+	}
+	return details
+}
+
+// FormatLocations lists every location of a secret, one per line. Findings stored
+// before locations were tracked fall back to their single file and line.
+func FormatLocations(secret models.SecretModel) string {
+	if len(secret.Locations) == 0 {
+		return "Line No: " + strconv.Itoa(secret.LineNo) + "\n" +
+			"File Location: " + secret.FileLocation + "\n"
+	}
+
+	details := "Locations (" + strconv.Itoa(len(secret.Locations)) + "):\n"
+	for _, location := range secret.Locations {
+		details += fmt.Sprintf("  %s [%s]\n", formatLocation(location), strings.Join(location.Patterns, ", "))
+	}
+	return details
+}
+
+// formatLocation renders a location as file:line:column, as the class and
+// method for dex code, or as the section and offset for native libraries
+func formatLocation(location models.SecretLocation) string {
+	if location.ClassName != "" {
+		return fmt.Sprintf("%s %s.%s", location.FileLocation, location.ClassName, location.MethodName)
+	}
+	if location.Section != "" {
+		return fmt.Sprintf("%s %s+0x%x", location.FileLocation, location.Section, location.Offset)
+	}
+	if location.LineNo == 0 {
+		return location.FileLocation
+	}
+	return fmt.Sprintf("%s:%d:%d", location.FileLocation, location.LineNo, location.ColumnNo)
+}
+
+// scoreFactorSummary lists the factors of a score as "base +70, entropy -15"
+func scoreFactorSummary(factors []models.ScoreFactor) string {
+	var parts []string
+	for _, factor := range factors {
+		parts = append(parts, fmt.Sprintf("%s %+d", factor.Factor, factor.Points))
+	}
+	return strings.Join(parts, ", ")
+}
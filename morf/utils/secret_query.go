@@ -0,0 +1,48 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"morf/models"
+	"sort"
+)
+
+// QuerySecrets returns the findings within the query's score range, sorted by
+// score when asked to (highest first unless order is asc). The input is not modified.
+func QuerySecrets(secrets []models.SecretModel, query models.SecretQuery) []models.SecretModel {
+	results := make([]models.SecretModel, 0, len(secrets))
+	for _, secret := range secrets {
+		if query.MinScore != nil && secret.Score < *query.MinScore {
+			continue
+		}
+		if query.MaxScore != nil && secret.Score > *query.MaxScore {
+			continue
+		}
+		results = append(results, secret)
+	}
+
+	if query.Sort == "score" {
+		ascending := query.Order == "asc"
+		sort.SliceStable(results, func(i, j int) bool {
+			if ascending {
+				return results[i].Score < results[j].Score
+			}
+			return results[i].Score > results[j].Score
+		})
+	}
+	return results
+}
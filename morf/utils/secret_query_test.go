@@ -0,0 +1,48 @@
+/*
+Copyright [2023] [Amrudesh Balakrishnan]
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"morf/models"
+	"testing"
+)
+
+func TestQuerySecrets(t *testing.T) {
+	secrets := []models.SecretModel{{Type: "a", Score: 40}, {Type: "b", Score: 90}, {Type: "c", Score: 65}, {Type: "d", Score: 10}}
+	minScore, maxScore := 30, 80
+
+	types := func(results []models.SecretModel) string {
+		var out string
+		for _, result := range results {
+			out += result.Type
+		}
+		return out
+	}
+
+	if got := types(QuerySecrets(secrets, models.SecretQuery{MinScore: &minScore})); got != "abc" {
+		t.Errorf("min_score: got %s", got)
+	}
+	if got := types(QuerySecrets(secrets, models.SecretQuery{MinScore: &minScore, MaxScore: &maxScore, Sort: "score"})); got != "ca" {
+		t.Errorf("range sorted: got %s", got)
+	}
+	if got := types(QuerySecrets(secrets, models.SecretQuery{Sort: "score", Order: "asc"})); got != "dacb" {
+		t.Errorf("ascending: got %s", got)
+	}
+	if got := types(secrets); got != "abcd" {
+		t.Errorf("input reordered: %s", got)
+	}
+}
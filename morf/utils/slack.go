@@ -163,7 +163,10 @@ func parseSlackData(data string) []string {
 		log.Error(apk_data)
 	}
 
-	if len(secrets.SecretModel) > 0 {
+	visible := UnsuppressedSecrets(secrets.SecretModel)
+	logSuppressed("Slack", len(secrets.SecretModel), len(visible))
+	if len(visible) > 0 {
+		secrets.SecretModel = visible
 		return parseSecretModel(secrets)
 	}
 	return []string{"** No secrets found **"}
@@ -183,12 +186,13 @@ func parseSecretModel(secrets models.Secrets) []string {
 		"" + strconv.Itoa(len(secrets.SecretModel)) + " secrets found\n" +
 		"----------------\n"
 
-	for _, value := range []models.SecretModel(secrets.SecretModel) {
+	for _, value := range RedactSecrets(secrets.SecretModel, RedactionMode(SinkSlack)) {
 		secretEntry := "Secret Type: " + value.Type + "\n" +
 			"Secret Value: " + value.SecretString + "\n" +
 			"Secret Type: " + value.SecretType + "\n" +
-			"Line No: " + strconv.Itoa(value.LineNo) + "\n" +
-			"File Location: " + value.FileLocation + "\n" +
+			"Fingerprint: " + value.Fingerprint + "\n" +
+			FormatLocations(value) +
+			FormatPatternDetails(value) +
 			"----------------\n"
 
 		if len(currentMessage)+len(secretEntry) > 4000 { // Slack has a 4000-character limit per message